@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkgos/proc/infra"
+)
+
+func Test_MaxBytes(t *testing.T) {
+	var evicted []string
+	tc := New(DefaultExpiration, 0, WithMaxBytes(100, nil)).OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+		if reason == EvictCapacity {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.Set("a", strings.Repeat("a", 39), DefaultExpiration) // 40 bytes
+	tc.Set("b", []byte(strings.Repeat("b", 39)), DefaultExpiration)
+	if n := tc.Bytes(); n != 80 {
+		t.Error("Bytes is not 80:", n)
+	}
+	tc.Get("a")
+	tc.Set("c", strings.Repeat("c", 39), DefaultExpiration) // evicts b
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Error("b was not evicted:", evicted)
+	}
+	if n := tc.Bytes(); n != 80 {
+		t.Error("Bytes is not 80:", n)
+	}
+
+	// replacing a with a larger value evicts c, never a itself.
+	tc.Set("a", strings.Repeat("a", 79), DefaultExpiration)
+	if len(evicted) != 2 || evicted[1] != "c" {
+		t.Error("c was not evicted:", evicted)
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("a was evicted by its own replacement")
+	}
+	if n := tc.Bytes(); n != 80 {
+		t.Error("Bytes is not 80:", n)
+	}
+
+	// an item larger than the budget is rejected.
+	tc.Set("d", strings.Repeat("d", 100), DefaultExpiration)
+	if _, found := tc.Get("d"); found {
+		t.Error("d was set, although it is larger than the budget")
+	}
+
+	tc.Delete("a")
+	if n := tc.Bytes(); n != 0 {
+		t.Error("Bytes is not 0 after Delete:", n)
+	}
+	tc.Set("e", "e", DefaultExpiration)
+	tc.Clear()
+	if n := tc.Bytes(); n != 0 {
+		t.Error("Bytes is not 0 after Clear:", n)
+	}
+}
+
+func Test_MaxBytesSizer(t *testing.T) {
+	sizer := func(_ string, x any) infra.ByteSize {
+		return infra.ByteSize(x.(int)) * infra.KByte
+	}
+	tc := NewFrom(DefaultExpiration, 0, map[string]Item{
+		"a": {Value: 2},
+		"b": {Value: 2},
+	}, WithMaxBytes(3*infra.KByte, sizer), WithEvictionPolicy(NewFIFOPolicy))
+	if n := tc.Bytes(); n != 2*infra.KByte {
+		t.Error("NewFrom did not trim the items to the budget:", infra.ByteSize(n))
+	}
+
+	tc = New(DefaultExpiration, 0, WithMaxBytes(3*infra.KByte, sizer))
+	tc.Set("a", 1, DefaultExpiration)
+	if _, err := tc.IncrInt("a", 1); err != nil {
+		t.Fatal("IncrInt failed:", err)
+	}
+	if n := tc.Bytes(); n != 2*infra.KByte {
+		t.Error("Bytes is not updated by IncrInt:", infra.ByteSize(n))
+	}
+	tc.Set("b", 1, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	if n := tc.Bytes(); n != 2*infra.KByte {
+		t.Error("Bytes is not updated by DeleteExpired:", infra.ByteSize(n))
+	}
+
+	// items from a sized cache are not accounted without a sizer.
+	oc := NewFrom(DefaultExpiration, 0, tc.Items())
+	oc.Delete("a")
+	if n := oc.Bytes(); n != 0 {
+		t.Error("Bytes of a cache without a sizer is not 0:", infra.ByteSize(n))
+	}
+
+	sc := NewSharded(2, DefaultExpiration, 0, WithMaxBytes(infra.MByte, nil))
+	sc.Set("a", "a", DefaultExpiration)
+	sc.Set("b", "bb", DefaultExpiration)
+	if n := sc.Bytes(); n != 5 {
+		t.Error("Bytes of the sharded cache is not 5:", n)
+	}
+}
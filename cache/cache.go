@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"encoding/gob"
 	"errors"
 	"io"
@@ -8,6 +9,8 @@ import (
 	"runtime"
 	"sync"
 	"time"
+
+	"github.com/thinkgos/proc/infra"
 )
 
 const (
@@ -23,11 +26,17 @@ var (
 	ErrValueNotFound       = errors.New("cache: value not found")
 	ErrValueNotValidNumber = errors.New("cache: value not a valid number")
 	ErrValueNotValidFloat  = errors.New("cache: value not a valid float32 or float64")
+	ErrValueOutOfRange     = errors.New("cache: value out of the int64 range")
 )
 
 type Item struct {
-	Value      any   // cache value
-	Expiration int64 // unix nanosecond
+	Value      any            // cache value
+	Expiration int64          // unix nanosecond
+	refreshAt  int64          // unix nanosecond, the item is stale after it, see WithRefreshAhead.
+	size       infra.ByteSize // size of the item, see WithMaxBytes.
+	ttl        time.Duration  // renewed on access, see WithSlidingExpiration.
+	deadline   int64          // unix nanosecond, the item expires by it, see WithMaxLifetime.
+	tags       []string       // see SetWithTags.
 }
 
 // Returns true if the item has expired.
@@ -35,17 +44,83 @@ func (i *Item) Expired() bool {
 	return i.Expiration > 0 && time.Now().UnixNano() > i.Expiration
 }
 
+// EvictReason is the reason why an item was evicted from the cache.
+type EvictReason int
+
+const (
+	// EvictExpired the item has expired.
+	EvictExpired EvictReason = iota
+	// EvictDeleted the item was deleted manually.
+	EvictDeleted
+	// EvictCleared the item was removed by Clear.
+	EvictCleared
+	// EvictCapacity the item was evicted to honour the capacity limits.
+	EvictCapacity
+	// EvictReplaced the item was overwritten by a new value.
+	EvictReplaced
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictCleared:
+		return "cleared"
+	case EvictCapacity:
+		return "capacity"
+	case EvictReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
 type Cache struct {
 	*cache
 	// If this is confusing, see the comment at the bottom of New()
 }
 
 type cache struct {
-	defaultExpiration time.Duration
-	items             map[string]Item
-	mu                sync.RWMutex
-	onEvicted         func(string, any)
-	janitor           *janitor
+	defaultExpiration  time.Duration
+	items              map[string]Item
+	expiry             expiryIndex // guarded by mu
+	mu                 sync.RWMutex
+	onEvicted          func(string, any, EvictReason)
+	onOverwrite        bool          // onEvicted is called for overwritten items
+	evicted            []evictedItem // pending eviction callbacks, guarded by mu
+	janitor            *janitor
+	maxEntries         int
+	maxEntryCost       int64
+	cost               func(string, any) int64
+	newPolicy          func() EvictionPolicy
+	policy             EvictionPolicy
+	maxBytes           infra.ByteSize
+	sizer              Sizer
+	bytes              infra.ByteSize                 // total size of the items, guarded by mu
+	tags               map[string]map[string]struct{} // keys of the tags, guarded by mu
+	sliding            bool
+	maxLifetime        time.Duration
+	loads              loadGroup
+	negativeExpiration time.Duration
+	refreshAfter       time.Duration
+	refresh            RefreshFunc
+	stats              stats
+	watchBuffer        int
+	watchers           map[*watcher]struct{} // guarded by mu
+	ctx                context.Context       // cancelled on close
+	cancel             context.CancelFunc
+	closeOnce          sync.Once
+	closed             bool           // guarded by mu, no more eviction callbacks once set
+	callbacks          sync.WaitGroup // eviction callbacks in progress
+}
+
+type evictedItem struct {
+	key    string
+	value  any
+	reason EvictReason
 }
 
 // Set an item to the cache, replacing any existing item.
@@ -54,11 +129,11 @@ type cache struct {
 func (c *cache) Set(k string, x any, d time.Duration) {
 	e := c.calcExpiration(d)
 	c.mu.Lock()
-	c.items[k] = Item{
+	c.set(k, Item{
 		Value:      x,
 		Expiration: e,
-	}
-	c.mu.Unlock()
+	})
+	c.unlock()
 }
 
 // SetDefault set an item to the cache, replacing any existing item, using the default
@@ -76,12 +151,12 @@ func (c *cache) SetNX(k string, x any, d time.Duration) bool {
 		c.mu.Unlock()
 		return false
 	}
-	c.items[k] = Item{
+	ok := c.set(k, Item{
 		Value:      x,
 		Expiration: c.calcExpiration(d),
-	}
-	c.mu.Unlock()
-	return true
+	})
+	c.unlock()
+	return ok
 }
 
 // SetXX set a new value for the cache key only if it already exists, and the existing
@@ -93,12 +168,12 @@ func (c *cache) SetXX(k string, x any, d time.Duration) (any, bool) {
 		c.mu.Unlock()
 		return nil, false
 	}
-	c.items[k] = Item{
+	ok := c.set(k, Item{
 		Value:      x,
 		Expiration: c.calcExpiration(d),
-	}
-	c.mu.Unlock()
-	return val, true
+	})
+	c.unlock()
+	return val, ok
 }
 
 type UpsertCb func(exist bool, valueInMap any) any
@@ -107,7 +182,8 @@ func (c *cache) Upsert(k string, cb UpsertCb, d time.Duration) (val any) {
 	var e int64
 
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+	var tags []string
 	v, found := c.items[k]
 	if !found || v.Expired() {
 		val = cb(false, nil)
@@ -115,11 +191,13 @@ func (c *cache) Upsert(k string, cb UpsertCb, d time.Duration) (val any) {
 	} else {
 		val = cb(true, v.Value)
 		e = v.Expiration
+		tags = v.tags
 	}
-	c.items[k] = Item{
+	c.set(k, Item{
 		Value:      val,
 		Expiration: e,
-	}
+		tags:       tags,
+	})
 	return val
 }
 
@@ -137,33 +215,39 @@ func (c *cache) calcExpiration(d time.Duration) int64 {
 // Get an item from the cache. Returns the item or nil, and a bool indicating
 // whether the key was found.
 func (c *cache) Get(k string) (any, bool) {
-	c.mu.RLock()
-	val, found := c.getValue(k)
-	c.mu.RUnlock()
-	return val, found
+	c.rlock()
+	item, found := c.access(k)
+	c.runlock()
+	c.stats.recordRead(found)
+	if found {
+		c.refreshIfStale(k, item)
+	}
+	return item.Value, found
 }
 
 type InsertCb func() any
 
 func (c *cache) GetOrNew(k string, cb InsertCb, d time.Duration) any {
-	c.mu.RLock()
-	val, found := c.getValue(k)
-	c.mu.RUnlock()
+	c.rlock()
+	item, found := c.access(k)
+	c.runlock()
+	c.stats.recordRead(found)
 	if found {
-		return val
+		c.refreshIfStale(k, item)
+		return item.Value
 	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	// double check
-	val, found = c.getValue(k)
+	item, found = c.access(k)
 	if found {
-		return val
+		return item.Value
 	}
-	val = cb()
-	c.items[k] = Item{
+	val := cb()
+	c.set(k, Item{
 		Value:      val,
 		Expiration: c.calcExpiration(d),
-	}
+	})
 	return val
 }
 
@@ -171,18 +255,17 @@ func (c *cache) GetOrNew(k string, cb InsertCb, d time.Duration) any {
 // whether the key was found. if key found, update with new expires.
 func (c *cache) GetEx(k string, d time.Duration) (any, bool) {
 	c.mu.Lock()
-	item, found := c.items[k]
+	item, found := c.getItem(k)
 	if !found {
 		c.mu.Unlock()
+		c.stats.recordRead(false)
 		return nil, false
 	}
-	if item.Expired() {
-		c.mu.Unlock()
-		return nil, false
-	}
-	item.Expiration = c.calcExpiration(d)
-	c.items[k] = item
+	item = c.renew(k, item, c.calcExpiration(d))
+	c.touch(k)
 	c.mu.Unlock()
+	c.stats.recordRead(true)
+	c.refreshIfStale(k, item)
 	return item.Value, true
 }
 
@@ -190,22 +273,15 @@ func (c *cache) GetEx(k string, d time.Duration) (any, bool) {
 // whether the key was found. if key found, delete it.
 func (c *cache) GetDel(k string) (any, bool) {
 	c.mu.Lock()
-	// "Inlining" of get and Expired
-	item, found := c.items[k]
+	item, found := c.getItem(k)
 	if !found {
 		c.mu.Unlock()
+		c.stats.recordRead(false)
 		return nil, false
 	}
-	if item.Expired() {
-		c.mu.Unlock()
-		return nil, false
-	}
-	delete(c.items, k)
-	onEvicted := c.onEvicted
-	c.mu.Unlock()
-	if onEvicted != nil {
-		onEvicted(k, item.Value)
-	}
+	c.remove(k, EvictDeleted)
+	c.unlock()
+	c.stats.recordRead(true)
 	return item.Value, true
 }
 
@@ -214,96 +290,91 @@ func (c *cache) GetDel(k string) (any, bool) {
 // never expires a zero value for time.Time is returned), and a bool indicating
 // whether the key was found.
 func (c *cache) GetWithExpiration(k string) (any, time.Time, bool) {
-	c.mu.RLock()
-	// "Inlining" of get and Expired
-	item, found := c.items[k]
+	c.rlock()
+	item, found := c.access(k)
+	c.runlock()
+	c.stats.recordRead(found)
 	if !found {
-		c.mu.RUnlock()
 		return nil, time.Time{}, false
 	}
-
+	c.refreshIfStale(k, item)
 	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
-			c.mu.RUnlock()
-			return nil, time.Time{}, false
-		}
 		// Return the item and the expiration time
-		c.mu.RUnlock()
 		return item.Value, time.Unix(0, item.Expiration), true
 	}
-
 	// If expiration <= 0 (i.e. no expiration time set) then return the item
 	// and a zeroed time.Time
-	c.mu.RUnlock()
 	return item.Value, time.Time{}, true
 }
 
 func (c *cache) getValue(k string) (any, bool) {
+	item, found := c.getItem(k)
+	return item.Value, found
+}
+
+func (c *cache) getItem(k string) (Item, bool) {
 	item, found := c.items[k]
 	if !found {
-		return nil, false
+		return Item{}, false
 	}
 	// "Inlining" of Expired
 	if item.Expired() {
-		return nil, false
+		return Item{}, false
 	}
-	return item.Value, true
+	return item, true
 }
 
 // Delete an item from the cache. Does nothing if the key is not in the cache.
 func (c *cache) Delete(k string) {
-	var val Item
-	var found bool
-
 	c.mu.Lock()
-	onEvicted := c.onEvicted
-	if onEvicted != nil {
-		val, found = c.items[k]
-	}
-	delete(c.items, k)
-	c.mu.Unlock()
-	if found {
-		onEvicted(k, val.Value)
-	}
+	c.remove(k, EvictDeleted)
+	c.unlock()
 }
 
-type keyValPair struct {
-	key   string
-	value any
-}
-
-// Delete all expired items from the cache.
+// Delete all expired items from the cache. Only the expired items are visited,
+// in order of expiration.
 func (c *cache) DeleteExpired() {
-	var evictedItems []keyValPair
-
 	now := time.Now().UnixNano()
 	c.mu.Lock()
-	onEvicted := c.onEvicted
-	for k, v := range c.items {
-		// "Inlining" of expired
-		if v.Expiration > 0 && now > v.Expiration {
-			delete(c.items, k)
-			if onEvicted != nil {
-				evictedItems = append(evictedItems, keyValPair{k, v.Value})
-			}
+	for {
+		k, ok := c.expiry.due(now)
+		if !ok {
+			break
 		}
+		c.remove(k, EvictExpired)
 	}
-	c.mu.Unlock()
-	for _, v := range evictedItems {
-		onEvicted(v.key, v.value)
-	}
+	c.unlock()
+	c.loads.deleteExpiredErrors(now)
 }
 
 // Clear all items from the cache.
 func (c *cache) Clear() {
 	c.mu.Lock()
 	onEvicted := c.onEvicted
+	if c.closed {
+		onEvicted = nil
+	}
 	old := c.items
+	if len(c.watchers) > 0 {
+		for k, v := range old {
+			c.notify(OpClear, k, v.Value, nil)
+		}
+	}
 	c.items = make(map[string]Item)
-	c.mu.Unlock()
+	c.bytes = 0
+	c.tags = nil
+	c.expiry.reset()
+	if c.policy != nil {
+		c.policy.Reset()
+	}
+	if onEvicted != nil {
+		c.callbacks.Add(1)
+		defer c.callbacks.Done()
+	}
+	c.unlock()
 	if onEvicted != nil {
 		for k, v := range old {
-			onEvicted(k, v.Value)
+			onEvicted(k, v.Value, EvictCleared)
 		}
 	}
 }
@@ -317,15 +388,158 @@ func (c *cache) Expire(k string, d time.Duration) bool {
 		c.mu.Unlock()
 		return false
 	}
-	item.Expiration = c.calcExpiration(d)
-	c.items[k] = item
+	c.renew(k, item, c.calcExpiration(d))
 	c.mu.Unlock()
 	return true
 }
 
-func (c *cache) setOnEvicted(f func(string, any)) {
+// set adds the item to the cache, evicting items as required by the capacity
+// limits. Returns false if the item was rejected because of its cost.
+// It must be called with c.mu held.
+func (c *cache) set(k string, item Item) bool {
+	if c.refreshAfter > 0 {
+		item.refreshAt = time.Now().Add(c.refreshAfter).UnixNano()
+	}
+	if c.sliding || c.maxLifetime > 0 {
+		c.setLifetime(k, &item)
+	}
+	item.size = 0 // the item may come from another cache, e.g. Load
+	if c.sizer != nil {
+		item.size = c.sizer(k, item.Value)
+	}
+	if (c.cost != nil && c.cost(k, item.Value) > c.maxEntryCost) ||
+		(c.maxBytes > 0 && item.size > c.maxBytes) {
+		// the item was never stored, so it is not an eviction.
+		c.remove(k, EvictCapacity)
+		c.stats.rejections.Add(1)
+		return false
+	}
+	if old, found := c.items[k]; found {
+		if old.Expired() {
+			c.addOverwritten(k, old.Value, EvictExpired)
+			c.notify(OpSet, k, nil, item.Value)
+		} else {
+			c.addOverwritten(k, old.Value, EvictReplaced)
+			c.notify(OpReplace, k, old.Value, item.Value)
+		}
+		c.bytes -= old.size
+		c.untagItem(k, old.tags)
+		if c.overBudget(item.size) {
+			// k must not be its own victim while making room.
+			c.policy.Remove(k)
+			c.evictBytes(item.size)
+			c.policy.Insert(k)
+		} else {
+			c.touch(k)
+		}
+	} else {
+		if c.maxEntries > 0 {
+			for len(c.items) >= c.maxEntries {
+				victim, ok := c.policy.Victim()
+				if !ok {
+					break
+				}
+				c.remove(victim, EvictCapacity)
+			}
+		}
+		c.evictBytes(item.size)
+		if c.policy != nil {
+			c.policy.Insert(k)
+		}
+		c.notify(OpSet, k, nil, item.Value)
+	}
+	c.items[k] = item
+	c.bytes += item.size
+	c.tagItem(k, item.tags)
+	c.expiry.set(k, item.Expiration)
+	c.stats.sets.Add(1)
+	return true
+}
+
+// remove deletes the item from the cache, and queues the eviction callback.
+// It must be called with c.mu held.
+func (c *cache) remove(k string, reason EvictReason) (Item, bool) {
+	item, found := c.items[k]
+	if c.policy != nil {
+		c.policy.Remove(k)
+	}
+	if !found {
+		return item, false
+	}
+	delete(c.items, k)
+	c.bytes -= item.size
+	c.untagItem(k, item.tags)
+	c.expiry.remove(k)
+	c.addEvicted(k, item.Value, reason)
+	return item, true
+}
+
+// overBudget reports whether adding size bytes exceeds the budget of WithMaxBytes.
+func (c *cache) overBudget(size infra.ByteSize) bool {
+	return c.maxBytes > 0 && c.bytes+size > c.maxBytes
+}
+
+// evictBytes evicts the victims of the policy until size bytes fit in the
+// budget of WithMaxBytes. It must be called with c.mu held.
+func (c *cache) evictBytes(size infra.ByteSize) {
+	for c.overBudget(size) {
+		victim, ok := c.policy.Victim()
+		if !ok {
+			break
+		}
+		c.remove(victim, EvictCapacity)
+	}
+}
+
+// touch records an access of the item to the eviction policy.
+// It must be called with c.mu held, read lock is enough.
+func (c *cache) touch(k string) {
+	if c.policy != nil {
+		c.policy.Access(k)
+	}
+}
+
+func (c *cache) addEvicted(k string, x any, reason EvictReason) {
+	c.stats.recordEvicted(reason)
+	c.notifyEvicted(k, x, reason)
+	if c.onEvicted != nil && !c.closed {
+		c.evicted = append(c.evicted, evictedItem{k, x, reason})
+	}
+}
+
+// addOverwritten behaves like addEvicted for the old value of an overwritten
+// item, which is only passed to the callbacks set by OnEvictedWithReason.
+func (c *cache) addOverwritten(k string, x any, reason EvictReason) {
+	c.stats.recordEvicted(reason)
+	c.notifyEvicted(k, x, reason)
+	if c.onEvicted != nil && c.onOverwrite && !c.closed {
+		c.evicted = append(c.evicted, evictedItem{k, x, reason})
+	}
+}
+
+// unlock releases c.mu, then runs the eviction callbacks queued while it was held.
+func (c *cache) unlock() {
+	evicted, onEvicted := c.evicted, c.onEvicted
+	c.evicted = nil
+	if len(evicted) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	// registered under mu, so that close waits for the callbacks.
+	c.callbacks.Add(1)
+	c.mu.Unlock()
+	defer c.callbacks.Done()
+	for _, v := range evicted {
+		onEvicted(v.key, v.value, v.reason)
+	}
+}
+
+// setOnEvicted sets the eviction callback, which is called for overwritten
+// items if overwrite is set.
+func (c *cache) setOnEvicted(f func(string, any, EvictReason), overwrite bool) {
 	c.mu.Lock()
 	c.onEvicted = f
+	c.onOverwrite = overwrite
 	c.mu.Unlock()
 }
 
@@ -334,18 +548,22 @@ func (c *cache) setOnEvicted(f func(string, any)) {
 // NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
 // documentation for NewFrom().)
 func (c *cache) Save(w io.Writer) (err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return saveItems(w, c.items)
+}
+
+func saveItems(w io.Writer, items map[string]Item) (err error) {
 	enc := gob.NewEncoder(w)
 	defer func() {
 		if x := recover(); x != nil {
 			err = errors.New("registering item types with Gob library")
 		}
 	}()
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	for _, v := range c.items {
+	for _, v := range items {
 		gob.Register(v.Value)
 	}
-	err = enc.Encode(&c.items)
+	err = enc.Encode(&items)
 	return
 }
 
@@ -377,18 +595,24 @@ func (c *cache) Load(r io.Reader) error {
 	items := map[string]Item{}
 	err := dec.Decode(&items)
 	if err == nil {
-		c.mu.Lock()
-		defer c.mu.Unlock()
-		for k, v := range items {
-			ov, found := c.items[k]
-			if !found || ov.Expired() {
-				c.items[k] = v
-			}
-		}
+		c.load(items)
 	}
 	return err
 }
 
+// load adds the items, excluding any items with keys that already exist
+// (and haven't expired) in the current cache.
+func (c *cache) load(items map[string]Item) {
+	c.mu.Lock()
+	defer c.unlock()
+	for k, v := range items {
+		ov, found := c.items[k]
+		if !found || ov.Expired() {
+			c.set(k, v)
+		}
+	}
+}
+
 // Load and add cache items from the given filename, excluding any items with
 // keys that already exist in the current cache.
 //
@@ -423,6 +647,16 @@ func (c *cache) Items() map[string]Item {
 	return m
 }
 
+// Bytes returns the total size of the items in the cache, as measured by the
+// Sizer of WithMaxBytes, zero without it. This may include items that have
+// expired, but have not yet been cleaned up.
+func (c *cache) Bytes() infra.ByteSize {
+	c.mu.RLock()
+	n := c.bytes
+	c.mu.RUnlock()
+	return n
+}
+
 // Count returns the number of items in the cache. This may include items that have
 // expired, but have not yet been cleaned up.
 func (c *cache) Count() int {
@@ -435,14 +669,17 @@ func (c *cache) Count() int {
 type janitor struct {
 	interval time.Duration
 	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
-func (j *janitor) Run(c *cache) {
+func (j *janitor) Run(deleteExpired func()) {
+	defer close(j.done)
 	ticker := time.NewTicker(j.interval)
 	for {
 		select {
 		case <-ticker.C:
-			c.DeleteExpired()
+			deleteExpired()
 		case <-j.stop:
 			ticker.Stop()
 			return
@@ -450,17 +687,44 @@ func (j *janitor) Run(c *cache) {
 	}
 }
 
+// Stop stops the janitor, and waits for a running cleanup to finish.
+// It is safe to call Stop more than once.
+func (j *janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
 func stopJanitor(c *Cache) {
-	c.janitor.stop <- struct{}{}
+	c.janitor.Stop()
 }
 
-func runJanitor(c *cache, ci time.Duration) {
+func runJanitor(deleteExpired func(), ci time.Duration) *janitor {
 	j := &janitor{
 		interval: ci,
 		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
-	c.janitor = j
-	go c.janitor.Run(c)
+	go j.Run(deleteExpired)
+	return j
+}
+
+// close stops the janitor and the background refreshes, and disables the
+// eviction callbacks. It returns after the eviction callbacks in progress
+// have returned. It is safe to call close more than once.
+func (c *cache) close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		if c.janitor != nil {
+			c.janitor.Stop()
+		}
+		c.mu.Lock()
+		c.closed = true
+		c.closeWatchers()
+		c.mu.Unlock()
+	})
+	c.callbacks.Wait()
 }
 
 // Return a new cache with a given default expiration duration and cleanup
@@ -468,8 +732,16 @@ func runJanitor(c *cache, ci time.Duration) {
 // the items in the cache never expire (by default), and must be deleted
 // manually. If the cleanup interval is less than one, expired items are not
 // deleted from the cache before calling c.DeleteExpired().
-func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
-	return NewFrom(defaultExpiration, cleanupInterval, make(map[string]Item))
+// Options customize the cache, such as WithMaxEntries to bound it.
+func New(defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Cache {
+	return NewFrom(defaultExpiration, cleanupInterval, make(map[string]Item), opts...)
+}
+
+// NewWithContext return a new cache like New(), which is closed when ctx is
+// done, see Close. The background refreshes of WithRefreshAhead are passed
+// a context derived from ctx.
+func NewWithContext(ctx context.Context, defaultExpiration, cleanupInterval time.Duration, opts ...Option) *Cache {
+	return newFrom(ctx, defaultExpiration, cleanupInterval, make(map[string]Item), opts...)
 }
 
 // Return a new cache with a given default expiration duration and cleanup
@@ -493,14 +765,12 @@ func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
 // gob.Register() the individual types stored in the cache before encoding a
 // map retrieved with c.Items(), and to register those same types before
 // decoding a blob containing an items map.
-func NewFrom(defaultExpiration, cleanupInterval time.Duration, items map[string]Item) *Cache {
-	if defaultExpiration == 0 {
-		defaultExpiration = -1
-	}
-	c := &cache{
-		defaultExpiration: defaultExpiration,
-		items:             items,
-	}
+func NewFrom(defaultExpiration, cleanupInterval time.Duration, items map[string]Item, opts ...Option) *Cache {
+	return newFrom(context.Background(), defaultExpiration, cleanupInterval, items, opts...)
+}
+
+func newFrom(ctx context.Context, defaultExpiration, cleanupInterval time.Duration, items map[string]Item, opts ...Option) *Cache {
+	c := newCache(ctx, defaultExpiration, items, opts...)
 	// This trick ensures that the janitor goroutine (which--granted it
 	// was enabled--is running DeleteExpired on c forever) does not keep
 	// the returned C object from being garbage collected. When it is
@@ -508,16 +778,96 @@ func NewFrom(defaultExpiration, cleanupInterval time.Duration, items map[string]
 	// which c can be collected.
 	C := &Cache{c}
 	if cleanupInterval > 0 {
-		runJanitor(c, cleanupInterval)
+		c.janitor = runJanitor(c.DeleteExpired, cleanupInterval)
 		runtime.SetFinalizer(C, stopJanitor)
 	}
+	// close holds no reference to C, so it does not keep C alive either.
+	context.AfterFunc(c.ctx, c.close)
 	return C
 }
 
+// Close stops the janitor and the background refreshes, instead of waiting
+// for the garbage collector to do so. Once Close returns, no OnEvicted
+// callback is running or will be called, although the cache can still be
+// used. Close must not be called from an OnEvicted callback, as it waits for
+// the callbacks in progress to return. It is safe to call Close more than
+// once, and it always returns nil.
+func (c *Cache) Close() error {
+	runtime.SetFinalizer(c, nil)
+	c.close()
+	return nil
+}
+
+func newCache(ctx context.Context, defaultExpiration time.Duration, items map[string]Item, opts ...Option) *cache {
+	if defaultExpiration == 0 {
+		defaultExpiration = -1
+	}
+	c := &cache{
+		defaultExpiration: defaultExpiration,
+		items:             items,
+		expiry:            newExpiryIndex(items),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	for _, f := range opts {
+		f(c)
+	}
+	if (c.maxEntries > 0 || c.maxBytes > 0) && c.newPolicy == nil {
+		c.newPolicy = NewLRUPolicy
+	}
+	for k, v := range items {
+		var size infra.ByteSize
+		if c.sizer != nil {
+			size = c.sizer(k, v.Value)
+		}
+		if v.size != size {
+			v.size = size
+			items[k] = v
+		}
+		c.bytes += size
+		c.tagItem(k, v.tags)
+	}
+	if c.newPolicy != nil {
+		c.policy = c.newPolicy()
+	}
+	if c.policy != nil {
+		for k := range items {
+			c.policy.Insert(k)
+		}
+		for (c.maxEntries > 0 && len(c.items) > c.maxEntries) || c.overBudget(0) {
+			victim, ok := c.policy.Victim()
+			if !ok {
+				break
+			}
+			c.remove(victim, EvictCapacity)
+		}
+	}
+	return c
+}
+
 // Sets an (optional) function that is called with the key and value when an
 // item is evicted from the cache. (Including when it is deleted manually, but
 // not when it is overwritten.) Set to nil to disable.
 func (c *Cache) OnEvicted(f func(string, any)) *Cache {
-	c.setOnEvicted(f)
+	c.setOnEvicted(ignoreReason(f), false)
+	return c
+}
+
+// ignoreReason adapts f to an eviction callback.
+func ignoreReason(f func(string, any)) func(string, any, EvictReason) {
+	if f == nil {
+		return nil
+	}
+	return func(k string, v any, _ EvictReason) {
+		f(k, v)
+	}
+}
+
+// OnEvictedWithReason sets an (optional) function that is called with the key,
+// value and the reason when an item is evicted from the cache, including when
+// it is overwritten (EvictReplaced, or EvictExpired if the old value had
+// expired, with the old value). It replaces the function set by OnEvicted.
+// Set to nil to disable.
+func (c *Cache) OnEvictedWithReason(f func(string, any, EvictReason)) *Cache {
+	c.setOnEvicted(f, true)
 	return c
 }
@@ -383,6 +383,72 @@ func Test_OnEvicted(t *testing.T) {
 	}
 }
 
+func Test_OnEvictedNotOnOverwrite(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	evicted := 0
+	tc.OnEvicted(func(string, any) { evicted++ })
+	tc.Set("foo", 1, DefaultExpiration)
+	tc.Set("foo", 2, DefaultExpiration)
+	tc.SetXX("foo", 3, DefaultExpiration)
+	if evicted != 0 {
+		t.Error("OnEvicted was called when foo was overwritten")
+	}
+	tc.Set("expired", 1, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.Set("expired", 2, DefaultExpiration)
+	if evicted != 0 {
+		t.Error("OnEvicted was called when an expired item was overwritten")
+	}
+	tc.Delete("foo")
+	if evicted != 1 {
+		t.Error("OnEvicted was not called when foo was deleted")
+	}
+}
+
+func Test_OnEvictedWithReason(t *testing.T) {
+	type evictedItem struct {
+		key    string
+		value  any
+		reason EvictReason
+	}
+	var evicted []evictedItem
+
+	tc := New(DefaultExpiration, 0)
+	tc.OnEvictedWithReason(func(k string, v any, reason EvictReason) {
+		evicted = append(evicted, evictedItem{k, v, reason})
+	})
+	tc.Set("foo", 1, DefaultExpiration)
+	tc.Set("foo", 2, DefaultExpiration)
+	tc.Upsert("foo", func(bool, any) any { return 3 }, DefaultExpiration)
+	tc.Delete("foo")
+	tc.Set("bar", 4, DefaultExpiration)
+	tc.GetDel("bar")
+	tc.Set("expired", 5, time.Millisecond)
+	tc.Set("overwritten", 6, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.Set("overwritten", 7, DefaultExpiration)
+	tc.DeleteExpired()
+	tc.Clear()
+
+	want := []evictedItem{
+		{"foo", 1, EvictReplaced},
+		{"foo", 2, EvictReplaced},
+		{"foo", 3, EvictDeleted},
+		{"bar", 4, EvictDeleted},
+		{"overwritten", 6, EvictExpired},
+		{"expired", 5, EvictExpired},
+		{"overwritten", 7, EvictCleared},
+	}
+	if len(evicted) != len(want) {
+		t.Fatalf("evicted items are not %v: %v", want, evicted)
+	}
+	for i := range want {
+		if evicted[i] != want[i] {
+			t.Errorf("evicted item %d is not %v: %v", i, want[i], evicted[i])
+		}
+	}
+}
+
 func Test_CacheSerialization(t *testing.T) {
 	tc := New(DefaultExpiration, 0)
 	testFillAndSerialize(t, tc)
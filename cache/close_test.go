@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Close(t *testing.T) {
+	var calls atomic.Int32
+	tc := New(DefaultExpiration, time.Millisecond).OnEvicted(func(string, any) {
+		calls.Add(1)
+	})
+	tc.Set("a", 1, 5*time.Millisecond)
+	tc.Set("b", 2, DefaultExpiration)
+	if err := tc.Close(); err != nil {
+		t.Fatal("Close failed:", err)
+	}
+	if err := tc.Close(); err != nil {
+		t.Error("second Close failed:", err)
+	}
+
+	<-time.After(15 * time.Millisecond)
+	if n := tc.Count(); n != 2 {
+		t.Error("janitor is still running after Close:", n)
+	}
+	tc.Delete("b")
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Clear()
+	if n := calls.Load(); n != 0 {
+		t.Errorf("OnEvicted was called %d times after Close", n)
+	}
+	if _, found := tc.Get("c"); found {
+		t.Error("c was found after Clear")
+	}
+}
+
+func Test_CloseWaitsForCallbacks(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var returned atomic.Bool
+	tc := New(DefaultExpiration, 0).OnEvicted(func(string, any) {
+		close(started)
+		<-release
+		returned.Store(true)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	go tc.Delete("a")
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		_ = tc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while OnEvicted was running")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	if !returned.Load() {
+		t.Error("Close returned before OnEvicted")
+	}
+}
+
+func Test_NewWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	tc := NewWithContext(ctx, DefaultExpiration, time.Millisecond).OnEvicted(func(string, any) {
+		calls.Add(1)
+	})
+	tc.Set("a", 1, DefaultExpiration)
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		tc.mu.RLock()
+		closed := tc.closed
+		tc.mu.RUnlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("cache was not closed when the context was cancelled")
+		}
+		<-time.After(time.Millisecond)
+	}
+	tc.Delete("a")
+	if n := calls.Load(); n != 0 {
+		t.Errorf("OnEvicted was called %d times after the context was cancelled", n)
+	}
+}
+
+func Test_ShardedClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	tc := NewShardedWithContext(ctx, 4, DefaultExpiration, time.Millisecond).OnEvicted(func(string, any) {
+		calls.Add(1)
+	})
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tc.Set(k, k, 5*time.Millisecond)
+	}
+	if err := tc.Close(); err != nil {
+		t.Fatal("Close failed:", err)
+	}
+	<-time.After(15 * time.Millisecond)
+	if n := tc.Count(); n != 4 {
+		t.Error("janitor is still running after Close:", n)
+	}
+	tc.DeleteExpired()
+	if n := calls.Load(); n != 0 {
+		t.Errorf("OnEvicted was called %d times after Close", n)
+	}
+}
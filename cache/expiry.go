@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"github.com/thinkgos/proc/go/heap"
+)
+
+// expiryIndex is a min-heap of the items with an expiration, ordered by
+// expiration, so that DeleteExpired only visits the items which are due
+// instead of walking every item.
+// It is guarded by c.mu, and must be updated whenever the expiration of an
+// item in c.items changes.
+type expiryIndex struct {
+	entries map[string]*expiryEntry
+	heap    expiryHeap
+}
+
+type expiryEntry struct {
+	key        string
+	expiration int64
+	index      int // index in the heap
+}
+
+type expiryHeap []*expiryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiration < h[j].expiration }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *expiryHeap) Push(e *expiryEntry) {
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *expiryHeap) Pop() *expiryEntry {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+func newExpiryIndex(items map[string]Item) expiryIndex {
+	x := expiryIndex{
+		entries: make(map[string]*expiryEntry),
+	}
+	for k, v := range items {
+		if v.Expiration > 0 {
+			e := &expiryEntry{key: k, expiration: v.Expiration, index: len(x.heap)}
+			x.entries[k] = e
+			x.heap = append(x.heap, e)
+		}
+	}
+	heap.Init(&x.heap)
+	return x
+}
+
+// set indexes the expiration of the key, an expiration <= 0 removes the key
+// from the index as it never expires.
+func (x *expiryIndex) set(k string, expiration int64) {
+	e, found := x.entries[k]
+	switch {
+	case expiration <= 0:
+		if found {
+			x.remove(k)
+		}
+	case found:
+		if e.expiration != expiration {
+			e.expiration = expiration
+			heap.Fix(&x.heap, e.index)
+		}
+	default:
+		e = &expiryEntry{key: k, expiration: expiration}
+		x.entries[k] = e
+		heap.Push(&x.heap, e)
+	}
+}
+
+func (x *expiryIndex) remove(k string) {
+	if e, found := x.entries[k]; found {
+		heap.Remove(&x.heap, e.index)
+		delete(x.entries, k)
+	}
+}
+
+func (x *expiryIndex) reset() {
+	x.entries = make(map[string]*expiryEntry)
+	x.heap = nil
+}
+
+// due returns the key which expires first if it has expired at now.
+func (x *expiryIndex) due(now int64) (string, bool) {
+	if len(x.heap) == 0 || x.heap[0].expiration >= now {
+		return "", false
+	}
+	return x.heap[0].key, true
+}
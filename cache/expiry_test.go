@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_DeleteExpiredOrder(t *testing.T) {
+	var evicted []string
+	tc := New(DefaultExpiration, 0).OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+		if reason == EvictExpired {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.Set("c", 3, 3*time.Millisecond)
+	tc.Set("a", 1, time.Millisecond)
+	tc.Set("b", 2, 2*time.Millisecond)
+	tc.Set("forever", 4, NoExpiration)
+	tc.Set("later", 5, time.Minute)
+	<-time.After(10 * time.Millisecond)
+
+	tc.DeleteExpired()
+	if !slices.Equal(evicted, []string{"a", "b", "c"}) {
+		t.Error("expired items were not evicted in order of expiration:", evicted)
+	}
+	if n := tc.Count(); n != 2 {
+		t.Error("DeleteExpired did not keep the unexpired items:", n)
+	}
+}
+
+func Test_ExpiryIndex(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, time.Millisecond)
+	tc.Set("b", 2, time.Millisecond)
+	tc.Set("c", 3, time.Millisecond)
+	tc.Set("d", 4, time.Millisecond)
+	tc.Set("a", 1, NoExpiration)     // no longer expires
+	tc.Expire("b", time.Minute)      // expires later
+	tc.GetEx("c", time.Minute)       // expires later
+	tc.Delete("d")                   // removed from the index
+	tc.Set("e", 5, time.Millisecond) // expires
+	tc.Set("f", 6, time.Millisecond) // expires
+	tc.Upsert("f", func(bool, any) any { return 7 }, DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+
+	tc.DeleteExpired()
+	for _, k := range []string{"a", "b", "c"} {
+		if _, found := tc.Get(k); !found {
+			t.Errorf("%s was deleted", k)
+		}
+	}
+	if n := tc.Count(); n != 3 {
+		t.Error("DeleteExpired did not delete e and f:", n)
+	}
+	if n := len(tc.expiry.entries); n != 2 || tc.expiry.heap.Len() != 2 {
+		t.Error("expiry index is not consistent with the items:", n, tc.expiry.heap.Len())
+	}
+
+	tc.Clear()
+	if n := len(tc.expiry.entries); n != 0 || tc.expiry.heap.Len() != 0 {
+		t.Error("Clear did not reset the expiry index:", n, tc.expiry.heap.Len())
+	}
+}
+
+func Test_ExpiryIndexNewFrom(t *testing.T) {
+	now := time.Now()
+	tc := NewFrom(DefaultExpiration, 0, map[string]Item{
+		"a": {Value: 1, Expiration: now.Add(-time.Minute).UnixNano()},
+		"b": {Value: 2, Expiration: now.Add(time.Minute).UnixNano()},
+		"c": {Value: 3},
+	})
+	tc.DeleteExpired()
+	if _, found := tc.items["a"]; found {
+		t.Error("a was not deleted")
+	}
+	if n := tc.Count(); n != 2 {
+		t.Error("DeleteExpired did not keep b and c:", n)
+	}
+}
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/thinkgos/proc/go/heap"
+)
+
+// defaultScanCount is the number of keys Scan returns when count <= 0.
+const defaultScanCount = 10
+
+// All returns an iterator over all unexpired items in the cache, without
+// copying them. The cache is read locked during the whole iteration, so the
+// loop body must not call the methods of the cache, and blocks the writers
+// until it returns. Range over Items instead to modify the cache while
+// iterating.
+func (c *cache) All() iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		now := time.Now().UnixNano()
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		for k, v := range c.items {
+			// "Inlining" of Expired
+			if v.Expiration > 0 && now > v.Expiration {
+				continue
+			}
+			if !yield(k, v.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Scan returns up to count unexpired keys matching match, in lexical order,
+// starting at cursor. The match is a glob pattern if it contains any of '*',
+// '?', '[' or '\\' (see MatchPattern), otherwise it is a key prefix; an empty
+// match matches all keys. Start with an empty cursor, and continue with the
+// returned next cursor until it is empty. If count <= 0, 10 keys are returned.
+//
+// Like Redis SCAN, it is not a snapshot: keys added or removed between calls
+// may or may not be returned. Every call visits all items, keeping only the
+// count smallest matching keys, so a page costs O(n log count) for n items.
+func (c *cache) Scan(match, cursor string, count int) (keys []string, next string) {
+	s := newKeySelector(count)
+	c.scanKeys(keyMatcher(match), cursor, s)
+	return s.page()
+}
+
+// scanKeys offers the unexpired keys matching matches, that are not less than
+// cursor, to s.
+func (c *cache) scanKeys(matches func(string) bool, cursor string, s *keySelector) {
+	now := time.Now().UnixNano()
+	c.mu.RLock()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		if k >= cursor && matches(k) {
+			s.offer(k)
+		}
+	}
+	c.mu.RUnlock()
+}
+
+// keySelector selects a Scan page: the count smallest keys offered, plus the
+// next one as the cursor of the following page. They are kept in a max-heap
+// of count+1 keys, so offering a key costs O(log count).
+type keySelector struct {
+	count int
+	keys  keyHeap
+}
+
+func newKeySelector(count int) *keySelector {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	return &keySelector{count: count}
+}
+
+// offer keeps k if it is one of the count+1 smallest keys offered.
+func (s *keySelector) offer(k string) {
+	if len(s.keys) <= s.count {
+		heap.Push(&s.keys, k)
+	} else if k < s.keys[0] {
+		s.keys[0] = k
+		heap.Fix(&s.keys, 0)
+	}
+}
+
+// page returns the selected keys in order, and the next cursor.
+func (s *keySelector) page() ([]string, string) {
+	keys := []string(s.keys)
+	slices.Sort(keys)
+	if len(keys) > s.count {
+		return keys[:s.count:s.count], keys[s.count]
+	}
+	return keys, ""
+}
+
+// keyHeap is a max-heap of keys.
+type keyHeap []string
+
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h keyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(k string)     { *h = append(*h, k) }
+func (h *keyHeap) Pop() string {
+	old := *h
+	n := len(old)
+	k := old[n-1]
+	*h = old[:n-1]
+	return k
+}
+
+// DeletePrefix deletes all items whose key starts with prefix, e.g. "user:123:"
+// to invalidate a namespace. Returns the number of unexpired items deleted.
+func (c *cache) DeletePrefix(prefix string) int {
+	n := 0
+	c.mu.Lock()
+	for k, v := range c.items {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if v.Expired() {
+			c.remove(k, EvictExpired)
+		} else {
+			c.remove(k, EvictDeleted)
+			n++
+		}
+	}
+	c.unlock()
+	return n
+}
+
+// GetMany gets the items of the keys from the cache under one lock.
+// Returns a map only containing the keys which were found.
+func (c *cache) GetMany(keys ...string) map[string]any {
+	m := make(map[string]any, len(keys))
+	items := make(map[string]Item, len(keys))
+
+	c.rlock()
+	for _, k := range keys {
+		item, found := c.access(k)
+		if found {
+			m[k] = item.Value
+			items[k] = item
+		}
+		c.stats.recordRead(found)
+	}
+	c.runlock()
+	for k, item := range items {
+		c.refreshIfStale(k, item)
+	}
+	return m
+}
+
+// SetMany sets all the items to the cache under one lock, replacing any
+// existing items, see Set.
+func (c *cache) SetMany(items map[string]any, d time.Duration) {
+	e := c.calcExpiration(d)
+	c.mu.Lock()
+	for k, x := range items {
+		c.set(k, Item{
+			Value:      x,
+			Expiration: e,
+		})
+	}
+	c.unlock()
+}
+
+// DeleteMany deletes the items of the keys from the cache under one lock.
+// Keys not in the cache are ignored.
+func (c *cache) DeleteMany(keys ...string) {
+	c.mu.Lock()
+	for _, k := range keys {
+		c.remove(k, EvictDeleted)
+	}
+	c.unlock()
+}
+
+// TTL returns the remaining time to live of an item, and a bool indicating
+// whether the key was found. If the item never expires, NoExpiration is returned.
+func (c *cache) TTL(k string) (time.Duration, bool) {
+	c.mu.RLock()
+	item, found := c.getItem(k)
+	c.mu.RUnlock()
+	if !found {
+		return 0, false
+	}
+	if item.Expiration <= 0 {
+		return NoExpiration, true
+	}
+	return max(time.Until(time.Unix(0, item.Expiration)), 0), true
+}
+
+// keyMatcher returns the matcher of a Scan match, see Scan.
+func keyMatcher(match string) func(string) bool {
+	if strings.ContainsAny(match, `*?[\`) {
+		return func(k string) bool { return MatchPattern(match, k) }
+	}
+	return func(k string) bool { return strings.HasPrefix(k, match) }
+}
+
+// MatchPattern reports whether s matches the glob pattern, in the style of
+// Redis KEYS:
+//
+//	'*'      matches any sequence of characters, including none
+//	'?'      matches any single character
+//	'[abc]'  matches one of the characters, '[^abc]' or '[!abc]' any other character
+//	'[a-z]'  matches a character in the range
+//	'\x'     matches x literally
+//
+// Unlike path.Match, '*' and '?' also match '/' and ':', and a malformed
+// pattern never matches rather than returning an error.
+func MatchPattern(pattern, s string) bool {
+	// backtracking to the last '*' only is enough, as each '*' can
+	// consume any of the characters the previous one could have.
+	px, sx := 0, 0
+	starPx, starSx := -1, -1
+	for sx < len(s) {
+		if px < len(pattern) {
+			switch pattern[px] {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(s[sx:])
+				px++
+				sx += size
+				continue
+			case '[':
+				r, size := utf8.DecodeRuneInString(s[sx:])
+				if n, ok := matchClass(pattern[px:], r); ok {
+					px += n
+					sx += size
+					continue
+				}
+			case '\\':
+				if px+1 < len(pattern) && pattern[px+1] == s[sx] {
+					px += 2
+					sx++
+					continue
+				}
+			default:
+				if pattern[px] == s[sx] {
+					px++
+					sx++
+					continue
+				}
+			}
+		}
+		if starPx < 0 {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(s[starSx:])
+		starSx += size
+		px, sx = starPx+1, starSx
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// matchClass matches r against the character class at the start of pattern.
+// Returns the length of the class, and whether r matched it.
+func matchClass(pattern string, r rune) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!')
+	if negate {
+		i++
+	}
+	matched := false
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return i + 1, matched != negate
+		}
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		lo, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			i++
+			if pattern[i] == '\\' && i+1 < len(pattern) {
+				i++
+			}
+			hi, size = utf8.DecodeRuneInString(pattern[i:])
+			i += size
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	// unterminated class
+	return 0, false
+}
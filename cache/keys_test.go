@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_All(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	got := make(map[string]any)
+	for k, v := range tc.All() {
+		got[k] = v
+	}
+	if len(got) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Error("All did not skip the expired items:", got)
+	}
+
+	sc := NewSharded(4, DefaultExpiration, 0)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		sc.Set(k, k, DefaultExpiration)
+	}
+	n := 0
+	for range sc.All() {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Error("All did not stop after break:", n)
+	}
+}
+
+func Test_Scan(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	for _, k := range []string{
+		"user:1:profile", "user:1:settings", "user:2:profile", "user:10:profile", "order:1",
+	} {
+		tc.Set(k, k, DefaultExpiration)
+	}
+	tc.Set("user:3:profile", 1, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+
+	tests := []struct {
+		match string
+		want  []string
+	}{
+		{"", []string{"order:1", "user:10:profile", "user:1:profile", "user:1:settings", "user:2:profile"}},
+		{"user:1:", []string{"user:1:profile", "user:1:settings"}},
+		{"user:*:profile", []string{"user:10:profile", "user:1:profile", "user:2:profile"}},
+		{"user:?:profile", []string{"user:1:profile", "user:2:profile"}},
+		{"user:[^1]:*", []string{"user:2:profile"}},
+		{"*:1", []string{"order:1"}},
+		{"user:[1", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		cursor := ""
+		for {
+			var keys []string
+			keys, cursor = tc.Scan(tt.match, cursor, 2)
+			if len(keys) > 2 {
+				t.Error("Scan returned more than count keys:", keys)
+			}
+			got = append(got, keys...)
+			if cursor == "" {
+				break
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Scan(%q) = %v, want %v", tt.match, got, tt.want)
+		}
+	}
+	if keys, next := tc.Scan("", "", 0); len(keys) != 5 || next != "" {
+		t.Error("Scan with the default count did not return all keys:", keys, next)
+	}
+
+	c := New(DefaultExpiration, 0)
+	want := make([]string, 0, 1000)
+	for i := range 1000 {
+		k := strconv.Itoa(i * 7919 % 1000)
+		c.Set(k, i, DefaultExpiration)
+		want = append(want, k)
+	}
+	slices.Sort(want)
+	var got []string
+	for cursor := ""; ; {
+		var keys []string
+		keys, cursor = c.Scan("", cursor, 7)
+		got = append(got, keys...)
+		if cursor == "" {
+			break
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Error("Scan did not return all keys in order:", len(got))
+	}
+}
+
+func Test_MatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "a/b:c", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"h?llo", "héllo", true},
+		{"h[ae]llo", "hello", true},
+		{"h[!ae]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[]]llo", "h]llo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"h[a", "ha", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_DeletePrefix(t *testing.T) {
+	var evicted []string
+	tc := NewSharded(4, DefaultExpiration, 0).OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+		if reason == EvictDeleted {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.Set("user:1:profile", 1, DefaultExpiration)
+	tc.Set("user:1:settings", 2, DefaultExpiration)
+	tc.Set("user:10:profile", 3, DefaultExpiration)
+
+	if n := tc.DeletePrefix("user:1:"); n != 2 {
+		t.Error("DeletePrefix did not delete 2 items:", n)
+	}
+	if _, found := tc.Get("user:10:profile"); !found {
+		t.Error("user:10:profile was deleted")
+	}
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []string{"user:1:profile", "user:1:settings"}) {
+		t.Error("OnEvicted was not called for the deleted items:", evicted)
+	}
+}
+
+func Test_Many(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	tc.SetMany(map[string]any{"a": 1, "b": 2, "c": 3}, DefaultExpiration)
+
+	got := tc.GetMany("a", "b", "missing")
+	if len(got) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Error("GetMany did not return a and b:", got)
+	}
+	if s := tc.Stats(); s.Hits != 2 || s.Misses != 1 {
+		t.Error("GetMany did not record the reads:", s)
+	}
+
+	tc.DeleteMany("a", "c", "missing")
+	if n := tc.Count(); n != 1 {
+		t.Error("DeleteMany did not delete a and c:", n)
+	}
+}
+
+func Test_TTL(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, time.Minute)
+
+	if d, found := tc.TTL("a"); !found || d != NoExpiration {
+		t.Error("TTL of a is not NoExpiration:", d, found)
+	}
+	if d, found := tc.TTL("b"); !found || d <= 0 || d > time.Minute {
+		t.Error("TTL of b is not within a minute:", d, found)
+	}
+	if _, found := tc.TTL("missing"); found {
+		t.Error("TTL found missing")
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoadFunc loads the value of a missing key, returns the value and its
+// expiration duration, which has the same meaning as in Set.
+type LoadFunc func(ctx context.Context) (any, time.Duration, error)
+
+// call is an in-flight or completed load.
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+	// canceled reports that the ctx of the caller which ran the load was done,
+	// so that the waiters with a live ctx load again.
+	canceled bool
+}
+
+type loadError struct {
+	err        error
+	expiration int64 // unix nanosecond
+}
+
+// loadGroup deduplicates concurrent loads of the same key, and holds the
+// negatively cached loader errors.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+	errs  map[string]loadError
+}
+
+// WithNegativeCache caches the error returned by a loader for d, during which
+// GetOrLoad returns the cached error instead of calling a loader again.
+// A value less than one disables negative caching, which is the default.
+func WithNegativeCache(d time.Duration) Option {
+	return func(c *cache) {
+		c.negativeExpiration = d
+	}
+}
+
+// GetOrLoad get an item from the cache, or loads it using loader if the key was
+// not found. Concurrent calls for the same key are deduplicated, only one of them
+// calls loader, the others wait for its result without holding any lock of the
+// cache. The loaded value is set to the cache with the duration returned by loader.
+// The error returned by loader is returned to all waiting callers, and is cached
+// if WithNegativeCache is set, unless it is a context error.
+//
+// loader is called with the ctx of the caller which runs it. A caller which
+// waits on another caller's load returns ctx.Err() when its own ctx is done.
+// If the load fails because the ctx of the caller which runs it is done, the
+// waiters whose ctx is still live load the key again.
+func (c *cache) GetOrLoad(ctx context.Context, k string, loader LoadFunc) (any, error) {
+	return c.getOrLoad(ctx, k, loader, c.Set)
+}
+
+// getOrLoad behaves like GetOrLoad, but stores the loaded value with set,
+// which may drop it.
+func (c *cache) getOrLoad(ctx context.Context, k string, loader LoadFunc, set func(k string, x any, d time.Duration)) (any, error) {
+	if val, found := c.Get(k); found {
+		return val, nil
+	}
+
+	g := &c.loads
+	for {
+		g.mu.Lock()
+		if le, ok := g.errs[k]; ok {
+			if time.Now().UnixNano() <= le.expiration {
+				g.mu.Unlock()
+				return nil, le.err
+			}
+			delete(g.errs, k)
+		}
+		if cl, ok := g.calls[k]; ok {
+			g.mu.Unlock()
+			select {
+			case <-cl.done:
+				if cl.canceled && ctx.Err() == nil {
+					continue // the load was abandoned, not failed.
+				}
+				return cl.val, cl.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		// double check, a load may have finished since the first lookup.
+		c.mu.RLock()
+		val, found := c.getValue(k)
+		c.mu.RUnlock()
+		if found {
+			g.mu.Unlock()
+			return val, nil
+		}
+		cl := g.newCall(k)
+		g.mu.Unlock()
+
+		if r := c.doLoad(ctx, k, cl, loader, set); r != nil {
+			panic(r)
+		}
+		return cl.val, cl.err
+	}
+}
+
+// isContextError reports whether err is a context error, which depends on
+// the caller, not on the key.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// newCall registers a new in-flight load of the key.
+// It must be called with g.mu held.
+func (g *loadGroup) newCall(k string) *call {
+	cl := &call{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	g.calls[k] = cl
+	return cl
+}
+
+// doLoad runs loader for the call, sets the loaded value to the cache with set,
+// then wakes up the waiting callers. A panic of loader fails the load, and is
+// recovered and returned, for the caller to re-panic on its own goroutine.
+func (c *cache) doLoad(ctx context.Context, k string, cl *call, loader LoadFunc, set func(k string, x any, d time.Duration)) (panicked any) {
+	g := &c.loads
+	start := time.Now()
+	defer func() {
+		r := recover()
+		if r != nil {
+			panicked = r
+			cl.val, cl.err = nil, fmt.Errorf("cache: loader panic: %v", r)
+		}
+		c.stats.recordLoad(time.Since(start), cl.err)
+		cl.canceled = cl.err != nil && ctx.Err() != nil
+		g.mu.Lock()
+		delete(g.calls, k)
+		if cl.err != nil && c.negativeExpiration > 0 && !isContextError(cl.err) {
+			if g.errs == nil {
+				g.errs = make(map[string]loadError)
+			}
+			g.errs[k] = loadError{
+				err:        cl.err,
+				expiration: time.Now().Add(c.negativeExpiration).UnixNano(),
+			}
+		}
+		g.mu.Unlock()
+		close(cl.done)
+	}()
+
+	val, d, err := loader(ctx)
+	if err != nil {
+		cl.err = err
+		return
+	}
+	set(k, val, d)
+	cl.val = val
+	return nil
+}
+
+// forget deletes the negatively cached error of the key.
+func (g *loadGroup) forget(k string) {
+	g.mu.Lock()
+	delete(g.errs, k)
+	g.mu.Unlock()
+}
+
+// deleteExpiredErrors deletes the expired negatively cached loader errors.
+func (g *loadGroup) deleteExpiredErrors(now int64) {
+	g.mu.Lock()
+	for k, le := range g.errs {
+		if now > le.expiration {
+			delete(g.errs, k)
+		}
+	}
+	g.mu.Unlock()
+}
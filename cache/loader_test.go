@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_GetOrLoad(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("foo", "bar", DefaultExpiration)
+
+	v, err := tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+		t.Error("loader was called for an existing key")
+		return nil, DefaultExpiration, nil
+	})
+	if err != nil || v.(string) != "bar" {
+		t.Error("foo is not bar:", v, err)
+	}
+
+	v, err = tc.GetOrLoad(context.Background(), "baz", func(context.Context) (any, time.Duration, error) {
+		return "qux", time.Minute, nil
+	})
+	if err != nil || v.(string) != "qux" {
+		t.Error("baz is not qux:", v, err)
+	}
+	_, expiration, found := tc.GetWithExpiration("baz")
+	if !found {
+		t.Error("baz was not set to the cache")
+	}
+	if expiration.IsZero() {
+		t.Error("expiration for baz is a zeroed time")
+	}
+}
+
+func Test_GetOrLoadDeduplicate(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(context.Context) (any, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return 42, DefaultExpiration, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]any, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := tc.GetOrLoad(context.Background(), "foo", loader)
+			if err != nil {
+				t.Error("GetOrLoad returned an error:", err)
+			}
+			results[i] = v
+		}()
+	}
+	// other keys are not blocked by the load in progress.
+	tc.Set("bar", 1, DefaultExpiration)
+	if _, found := tc.Get("bar"); !found {
+		t.Error("bar was not found")
+	}
+	<-time.After(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader was called %d times", n)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("result %d is not 42: %v", i, v)
+		}
+	}
+}
+
+func Test_GetOrLoadError(t *testing.T) {
+	errLoad := errors.New("load failed")
+
+	tc := New(DefaultExpiration, 0)
+	calls := 0
+	loader := func(context.Context) (any, time.Duration, error) {
+		calls++
+		return nil, DefaultExpiration, errLoad
+	}
+	for range 2 {
+		if _, err := tc.GetOrLoad(context.Background(), "foo", loader); !errors.Is(err, errLoad) {
+			t.Error("GetOrLoad did not return the loader error:", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("loader was called %d times without negative caching", calls)
+	}
+	if _, found := tc.Get("foo"); found {
+		t.Error("foo was found, but the load failed")
+	}
+}
+
+func Test_GetOrLoadNegativeCache(t *testing.T) {
+	errLoad := errors.New("load failed")
+
+	tc := New(DefaultExpiration, 0, WithNegativeCache(20*time.Millisecond))
+	calls := 0
+	loader := func(context.Context) (any, time.Duration, error) {
+		calls++
+		if calls == 1 {
+			return nil, DefaultExpiration, errLoad
+		}
+		return "bar", DefaultExpiration, nil
+	}
+	for range 2 {
+		if _, err := tc.GetOrLoad(context.Background(), "foo", loader); !errors.Is(err, errLoad) {
+			t.Error("GetOrLoad did not return the loader error:", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader was called %d times with negative caching", calls)
+	}
+
+	<-time.After(25 * time.Millisecond)
+	tc.DeleteExpired()
+	v, err := tc.GetOrLoad(context.Background(), "foo", loader)
+	if err != nil || v.(string) != "bar" {
+		t.Error("foo is not bar after the negative cache expired:", v, err)
+	}
+}
+
+func Test_GetOrLoadWaiterContext(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _ = tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+			close(started)
+			<-release
+			return 1, DefaultExpiration, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := tc.GetOrLoad(ctx, "foo", func(context.Context) (any, time.Duration, error) {
+		t.Error("loader was called twice")
+		return nil, DefaultExpiration, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("GetOrLoad did not return the context error:", err)
+	}
+	close(release)
+}
+
+func Test_GetOrLoadLeaderContext(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithNegativeCache(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	leader := make(chan error, 1)
+	go func() {
+		_, err := tc.GetOrLoad(ctx, "foo", func(ctx context.Context) (any, time.Duration, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, DefaultExpiration, ctx.Err()
+		})
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan any, 1)
+	go func() {
+		v, err := tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+			return "bar", DefaultExpiration, nil
+		})
+		if err != nil {
+			t.Error("the waiter got the error of the canceled load:", err)
+		}
+		waiter <- v
+	}()
+	<-time.After(10 * time.Millisecond) // let the waiter wait on the load
+	cancel()
+
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Error("GetOrLoad did not return the context error:", err)
+	}
+	if v := <-waiter; v != "bar" {
+		t.Error("the waiter did not load foo again:", v)
+	}
+
+	tc.Delete("foo")
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, _ = tc.GetOrLoad(ctx, "foo", func(ctx context.Context) (any, time.Duration, error) {
+		return nil, DefaultExpiration, ctx.Err()
+	})
+	v, err := tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+		return "baz", DefaultExpiration, nil
+	})
+	if err != nil || v != "baz" {
+		t.Error("the context error was negatively cached:", v, err)
+	}
+}
+
+func Test_GetOrLoadPanic(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("loader panic was not propagated")
+			}
+		}()
+		_, _ = tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+			panic("boom")
+		})
+	}()
+	v, err := tc.GetOrLoad(context.Background(), "foo", func(context.Context) (any, time.Duration, error) {
+		return 1, DefaultExpiration, nil
+	})
+	if err != nil || v.(int) != 1 {
+		t.Error("foo is not 1 after a panicked load:", v, err)
+	}
+}
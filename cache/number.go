@@ -2,6 +2,8 @@ package cache
 
 import (
 	"fmt"
+	"math"
+	"time"
 )
 
 type Number interface {
@@ -53,7 +55,7 @@ func (c *cache) Incr(k string, n int64) error {
 		c.mu.Unlock()
 		return ErrValueNotValidNumber
 	}
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nil
 }
@@ -78,7 +80,7 @@ func (c *cache) IncrFloat(k string, n float64) error {
 		c.mu.Unlock()
 		return ErrValueNotValidFloat
 	}
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nil
 }
@@ -218,7 +220,7 @@ func (c *cache) Decr(k string, n int64) error {
 		c.mu.Unlock()
 		return ErrValueNotValidNumber
 	}
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nil
 }
@@ -243,7 +245,7 @@ func (c *cache) DecrFloat(k string, n float64) error {
 		c.mu.Unlock()
 		return ErrValueNotValidFloat
 	}
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nil
 }
@@ -355,7 +357,7 @@ func incr[T Number](c *cache, k string, n T) (T, error) {
 	}
 	nv = rv + n
 	v.Value = nv
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nv, nil
 }
@@ -376,7 +378,238 @@ func decr[T Number](c *cache, k string, n T) (T, error) {
 	}
 	nv = rv - n
 	v.Value = nv
-	c.items[k] = v
+	c.updateNumber(k, v)
 	c.mu.Unlock()
 	return nv, nil
 }
+
+// updateNumber stores the incremented or decremented item.
+// It must be called with c.mu held.
+func (c *cache) updateNumber(k string, v Item) {
+	c.notify(OpIncr, k, c.items[k].Value, v.Value)
+	if c.sizer != nil {
+		c.bytes -= v.size
+		v.size = c.sizer(k, v.Value)
+		c.bytes += v.size
+	}
+	c.items[k] = v
+}
+
+// IncrBy increment an item of an integer type by n, like Incr, and returns the
+// incremented value. If the key does not exist or has expired, it is created
+// as an int64 of n with the expiration d, like Redis INCRBY. The value is
+// computed as an int64, and saturates at the range of the item's type, so an
+// unsigned value never goes below 0; the value returned is the value stored.
+// Returns an error if the item's value is not an integer, or
+// ErrValueOutOfRange if it is an unsigned value above math.MaxInt64.
+func (c *cache) IncrBy(k string, n int64, d time.Duration) (int64, error) {
+	return c.incrBy(k, n, math.MinInt64, math.MaxInt64, d)
+}
+
+// DecrBy decrement an item of an integer type by n, see IncrBy.
+func (c *cache) DecrBy(k string, n int64, d time.Duration) (int64, error) {
+	return c.incrBy(k, -n, math.MinInt64, math.MaxInt64, d)
+}
+
+// IncrByClamp increment an item of an integer type by n like IncrBy, but
+// clamps the value to [lo, hi], so a bounded counter, e.g. the tokens of a
+// rate limiter, neither overflows nor underflows. Pass a negative n to
+// decrement the value.
+func (c *cache) IncrByClamp(k string, n, lo, hi int64, d time.Duration) (int64, error) {
+	return c.incrBy(k, n, lo, hi, d)
+}
+
+// IncrByFloat increment an item of type float32 or float64 by n, and returns the
+// incremented value. If the key does not exist or has expired, it is created as
+// a float64 of n with the expiration d. Returns an error if the item's value is
+// not floating point.
+func (c *cache) IncrByFloat(k string, n float64, d time.Duration) (float64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	v, found := c.getItem(k)
+	if !found {
+		c.set(k, Item{
+			Value:      n,
+			Expiration: c.calcExpiration(d),
+		})
+		return n, nil
+	}
+	var nv float64
+	switch vv := v.Value.(type) {
+	case float32:
+		v.Value = vv + float32(n)
+		nv = float64(v.Value.(float32))
+	case float64:
+		nv = vv + n
+		v.Value = nv
+	default:
+		return 0, ErrValueNotValidFloat
+	}
+	c.updateNumber(k, v)
+	return nv, nil
+}
+
+// DecrByFloat decrement an item of type float32 or float64 by n, see IncrByFloat.
+func (c *cache) DecrByFloat(k string, n float64, d time.Duration) (float64, error) {
+	return c.IncrByFloat(k, -n, d)
+}
+
+func (c *cache) incrBy(k string, n, lo, hi int64, d time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+	v, found := c.getItem(k)
+	if !found {
+		nv := clamp(n, lo, hi)
+		c.set(k, Item{
+			Value:      nv,
+			Expiration: c.calcExpiration(d),
+		})
+		return nv, nil
+	}
+	rv, err := integerValue(v.Value)
+	if err != nil {
+		return 0, err
+	}
+	nv := rv + n
+	// saturate on overflow, before clamping.
+	if n > 0 && nv < rv {
+		nv = math.MaxInt64
+	} else if n < 0 && nv > rv {
+		nv = math.MinInt64
+	}
+	// clamp to [lo, hi], then to the range of the item's type, so that the
+	// value returned is the value stored.
+	tlo, thi := integerRange(v.Value)
+	nv = clamp(clamp(nv, lo, hi), tlo, thi)
+	v.Value = integerOf(v.Value, nv)
+	c.updateNumber(k, v)
+	return nv, nil
+}
+
+func clamp(n, lo, hi int64) int64 {
+	return min(max(n, lo), hi)
+}
+
+// integerValue returns the value of an integer type as an int64. Returns
+// ErrValueOutOfRange for an unsigned value above math.MaxInt64.
+func integerValue(x any) (int64, error) {
+	var u uint64
+	switch vv := x.(type) {
+	case int:
+		return int64(vv), nil
+	case int8:
+		return int64(vv), nil
+	case int16:
+		return int64(vv), nil
+	case int32:
+		return int64(vv), nil
+	case int64:
+		return vv, nil
+	case uint8:
+		return int64(vv), nil
+	case uint16:
+		return int64(vv), nil
+	case uint32:
+		return int64(vv), nil
+	case uint:
+		u = uint64(vv)
+	case uintptr:
+		u = uint64(vv)
+	case uint64:
+		u = vv
+	default:
+		return 0, ErrValueNotValidNumber
+	}
+	if u > math.MaxInt64 {
+		return 0, ErrValueOutOfRange
+	}
+	return int64(u), nil
+}
+
+// integerRange returns the range of the integer type of x, within the int64
+// range the counters are computed in.
+func integerRange(x any) (lo, hi int64) {
+	switch x.(type) {
+	case int:
+		return math.MinInt, math.MaxInt
+	case int8:
+		return math.MinInt8, math.MaxInt8
+	case int16:
+		return math.MinInt16, math.MaxInt16
+	case int32:
+		return math.MinInt32, math.MaxInt32
+	case uint8:
+		return 0, math.MaxUint8
+	case uint16:
+		return 0, math.MaxUint16
+	case uint32:
+		return 0, math.MaxUint32
+	case uint:
+		return 0, int64(min(uint64(math.MaxUint), math.MaxInt64))
+	case uintptr:
+		return 0, int64(min(uint64(^uintptr(0)), math.MaxInt64))
+	case uint64:
+		return 0, math.MaxInt64
+	default:
+		return math.MinInt64, math.MaxInt64
+	}
+}
+
+// integerOf converts n, within the range of the integer type of x, to the type.
+func integerOf(x any, n int64) any {
+	switch x.(type) {
+	case int:
+		return int(n)
+	case int8:
+		return int8(n)
+	case int16:
+		return int16(n)
+	case int32:
+		return int32(n)
+	case uint:
+		return uint(n)
+	case uintptr:
+		return uintptr(n)
+	case uint8:
+		return uint8(n)
+	case uint16:
+		return uint16(n)
+	case uint32:
+		return uint32(n)
+	case uint64:
+		return uint64(n)
+	default:
+		return n
+	}
+}
+
+// CompareAndSwap swaps the value of an item for new if the value is equal to
+// old, keeping the expiration of the item. Returns false if the key does not
+// exist, has expired, or its value is not equal to old. The old value must be
+// of a comparable type, like sync.Map.CompareAndSwap.
+func (c *cache) CompareAndSwap(k string, old, new any) bool {
+	c.mu.Lock()
+	defer c.unlock()
+	v, found := c.getItem(k)
+	if !found || v.Value != old {
+		return false
+	}
+	return c.set(k, Item{
+		Value:      new,
+		Expiration: v.Expiration,
+		tags:       v.tags,
+	})
+}
+
+// GetSet set an item to the cache, replacing any existing item like Set, and
+// returns the previous value, and a bool indicating whether the key was found.
+func (c *cache) GetSet(k string, x any, d time.Duration) (any, bool) {
+	c.mu.Lock()
+	defer c.unlock()
+	old, found := c.getValue(k)
+	c.set(k, Item{
+		Value:      x,
+		Expiration: c.calcExpiration(d),
+	})
+	return old, found
+}
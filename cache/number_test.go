@@ -1,6 +1,12 @@
 package cache
 
-import "testing"
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
 
 func Test_Incr_Int(t *testing.T) {
 	tc := New(DefaultExpiration, 0)
@@ -1001,3 +1007,163 @@ func Test_DecrUnderflowUint(t *testing.T) {
 		t.Error("uint8 did not underflow as expected; value:", uint8)
 	}
 }
+
+func Test_IncrBy(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	n, err := tc.IncrBy("counter", 2, time.Minute)
+	if err != nil || n != 2 {
+		t.Error("IncrBy did not create counter with 2:", n, err)
+	}
+	if d, _ := tc.TTL("counter"); d <= 0 || d > time.Minute {
+		t.Error("IncrBy did not create counter with the expiration:", d)
+	}
+	n, err = tc.DecrBy("counter", 5, DefaultExpiration)
+	if err != nil || n != -3 {
+		t.Error("counter is not -3:", n, err)
+	}
+	if x, _ := tc.Get("counter"); x.(int64) != -3 {
+		t.Error("counter is not an int64 of -3:", x)
+	}
+
+	tc.Set("tuint8", uint8(250), DefaultExpiration)
+	n, err = tc.IncrBy("tuint8", 3, DefaultExpiration)
+	if err != nil || n != 253 {
+		t.Error("tuint8 is not 253:", n, err)
+	}
+	if x, _ := tc.Get("tuint8"); x.(uint8) != 253 {
+		t.Error("tuint8 is not an uint8 of 253:", x)
+	}
+
+	tc.Set("max", int64(math.MaxInt64-1), DefaultExpiration)
+	if n, _ := tc.IncrBy("max", 10, DefaultExpiration); n != math.MaxInt64 {
+		t.Error("IncrBy did not saturate on overflow:", n)
+	}
+
+	tc.Set("tstring", "a", DefaultExpiration)
+	if _, err := tc.IncrBy("tstring", 1, DefaultExpiration); !errors.Is(err, ErrValueNotValidNumber) {
+		t.Error("IncrBy did not fail on a string:", err)
+	}
+}
+
+func Test_IncrByTypeRange(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tests := []struct {
+		name   string
+		value  any
+		n      int64
+		lo, hi int64
+		want   int64
+		stored any
+	}{
+		{"int8 max", int8(math.MaxInt8), 1, math.MinInt64, math.MaxInt64, math.MaxInt8, int8(math.MaxInt8)},
+		{"int8 min", int8(math.MinInt8), -1, math.MinInt64, math.MaxInt64, math.MinInt8, int8(math.MinInt8)},
+		{"int8 clamp", int8(0), 500, 0, 1000, math.MaxInt8, int8(math.MaxInt8)},
+		{"uint zero", uint(0), -1, math.MinInt64, math.MaxInt64, 0, uint(0)},
+		{"uint clamp", uint(5), -10, -20, 20, 0, uint(0)},
+		{"uint8 max", uint8(250), 10, math.MinInt64, math.MaxInt64, math.MaxUint8, uint8(math.MaxUint8)},
+		{"uint64 zero", uint64(1), -2, math.MinInt64, math.MaxInt64, 0, uint64(0)},
+		{"uint64 max", uint64(math.MaxInt64 - 1), 5, math.MinInt64, math.MaxInt64, math.MaxInt64, uint64(math.MaxInt64)},
+	}
+	for _, test := range tests {
+		tc.Set("k", test.value, DefaultExpiration)
+		n, err := tc.IncrByClamp("k", test.n, test.lo, test.hi, DefaultExpiration)
+		if err != nil || n != test.want {
+			t.Errorf("%s: IncrByClamp returned %d, %v, want %d", test.name, n, err, test.want)
+		}
+		if x, _ := tc.Get("k"); x != test.stored {
+			t.Errorf("%s: stored %v (%T), want %v (%T)", test.name, x, x, test.stored, test.stored)
+		}
+	}
+
+	tc.Set("k", uint(0), DefaultExpiration)
+	if n, _ := tc.DecrBy("k", 1, DefaultExpiration); n != 0 {
+		t.Error("DecrBy did not saturate uint at 0:", n)
+	}
+	tc.Set("k", int8(math.MaxInt8), DefaultExpiration)
+	if n, _ := tc.IncrBy("k", 1, DefaultExpiration); n != math.MaxInt8 {
+		t.Error("IncrBy did not saturate int8 at its max:", n)
+	}
+
+	tc.Set("k", uint64(math.MaxUint64), DefaultExpiration)
+	if _, err := tc.IncrBy("k", -1, DefaultExpiration); !errors.Is(err, ErrValueOutOfRange) {
+		t.Error("IncrBy did not fail on an uint64 above math.MaxInt64:", err)
+	}
+	if x, _ := tc.Get("k"); x.(uint64) != math.MaxUint64 {
+		t.Error("the uint64 above math.MaxInt64 was changed:", x)
+	}
+}
+
+func Test_IncrByFloat(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	f, err := tc.IncrByFloat("float", 1.5, DefaultExpiration)
+	if err != nil || f != 1.5 {
+		t.Error("IncrByFloat did not create float with 1.5:", f, err)
+	}
+	tc.Set("float32", float32(1.5), DefaultExpiration)
+	if f, err := tc.DecrByFloat("float32", 1, DefaultExpiration); err != nil || f != 0.5 {
+		t.Error("float32 is not 0.5:", f, err)
+	}
+	if x, _ := tc.Get("float32"); x.(float32) != 0.5 {
+		t.Error("float32 is not a float32 of 0.5:", x)
+	}
+	tc.Set("int", 1, DefaultExpiration)
+	if _, err := tc.IncrByFloat("int", 1, DefaultExpiration); !errors.Is(err, ErrValueNotValidFloat) {
+		t.Error("IncrByFloat did not fail on an int:", err)
+	}
+}
+
+func Test_IncrByClamp(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				_, _ = tc.IncrByClamp("tokens", 1, 0, 100, DefaultExpiration)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := tc.IncrByClamp("tokens", 1, 0, 100, DefaultExpiration); n != 100 {
+		t.Error("tokens is not clamped at 100:", n)
+	}
+	if n, _ := tc.IncrByClamp("tokens", -150, 0, 100, DefaultExpiration); n != 0 {
+		t.Error("tokens is not clamped at 0:", n)
+	}
+	if n, _ := tc.IncrByClamp("new", -5, 0, 100, DefaultExpiration); n != 0 {
+		t.Error("new is not clamped at 0:", n)
+	}
+}
+
+func Test_CompareAndSwap(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	if tc.CompareAndSwap("a", nil, 1) {
+		t.Error("CompareAndSwap swapped a missing key")
+	}
+	tc.Set("a", 1, time.Minute)
+	_, expiration, _ := tc.GetWithExpiration("a")
+	if tc.CompareAndSwap("a", 2, 3) {
+		t.Error("CompareAndSwap swapped a different value")
+	}
+	if !tc.CompareAndSwap("a", 1, 3) {
+		t.Error("CompareAndSwap did not swap 1 for 3")
+	}
+	x, e, _ := tc.GetWithExpiration("a")
+	if x.(int) != 3 || !e.Equal(expiration) {
+		t.Error("a is not 3 with the same expiration:", x, e)
+	}
+}
+
+func Test_GetSet(t *testing.T) {
+	tc := NewSharded(2, DefaultExpiration, 0)
+	if old, found := tc.GetSet("a", 1, DefaultExpiration); found || old != nil {
+		t.Error("GetSet found a missing key:", old)
+	}
+	if old, found := tc.GetSet("a", 2, DefaultExpiration); !found || old.(int) != 1 {
+		t.Error("GetSet did not return 1:", old)
+	}
+	if x, _ := tc.Get("a"); x.(int) != 2 {
+		t.Error("a is not 2:", x)
+	}
+}
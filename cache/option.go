@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/thinkgos/proc/infra"
+)
+
+// Option customize the cache
+type Option func(*cache)
+
+// WithMaxEntries bounds the number of items the cache holds. When an item is
+// added to a full cache, the victim of the eviction policy is evicted first
+// with EvictCapacity. If no policy is set, NewLRUPolicy() is used.
+// A value less than one means unlimited.
+func WithMaxEntries(n int) Option {
+	return func(c *cache) {
+		c.maxEntries = n
+	}
+}
+
+// WithMaxEntryCost rejects any item whose cost, as calculated by cost, exceeds
+// maxCost. A rejected item is reported to the eviction callback with
+// EvictCapacity, and the item it would have replaced is evicted too.
+func WithMaxEntryCost(maxCost int64, cost func(k string, x any) int64) Option {
+	return func(c *cache) {
+		c.maxEntryCost = maxCost
+		c.cost = cost
+	}
+}
+
+// WithEvictionPolicy customize the eviction policy used by a bounded cache,
+// default NewLRUPolicy. newPolicy is called once for every cache (or shard)
+// created with this option, e.g. WithEvictionPolicy(NewLFUPolicy).
+func WithEvictionPolicy(newPolicy func() EvictionPolicy) Option {
+	return func(c *cache) {
+		c.newPolicy = newPolicy
+	}
+}
+
+// Sizer returns the size of an item, such as the length of a serialized payload.
+type Sizer func(k string, x any) infra.ByteSize
+
+// DefaultSizer measures the key, and values of type string or []byte by len.
+// Values of other types count as zero bytes, use a Sizer of their own for them.
+func DefaultSizer(k string, x any) infra.ByteSize {
+	n := len(k)
+	switch v := x.(type) {
+	case string:
+		n += len(v)
+	case []byte:
+		n += len(v)
+	}
+	return infra.ByteSize(n)
+}
+
+// WithMaxBytes bounds the total size of the items the cache holds, as measured
+// by sizer, DefaultSizer if nil. When an item does not fit in the budget, the
+// victims of the eviction policy are evicted first with EvictCapacity, and an
+// item larger than the budget itself is rejected like WithMaxEntryCost.
+// If no policy is set, NewLRUPolicy() is used. Zero means unlimited.
+// The current usage is reported by Bytes.
+func WithMaxBytes(maxBytes infra.ByteSize, sizer Sizer) Option {
+	return func(c *cache) {
+		if sizer == nil {
+			sizer = DefaultSizer
+		}
+		c.maxBytes = maxBytes
+		c.sizer = sizer
+	}
+}
+
+// WithSlidingExpiration renews the expiration of an item on every read, such
+// as Get, GetOrNew, GetWithExpiration and GetMany, by the duration it was set
+// with, so an item only expires after being idle for that duration, like a
+// session. Reads take the write lock of the cache then.
+// Items which never expire are not affected.
+func WithSlidingExpiration() Option {
+	return func(c *cache) {
+		c.sliding = true
+	}
+}
+
+// WithMaxLifetime caps the lifetime of every item at d since the key was set,
+// however often its expiration is renewed by WithSlidingExpiration, GetEx or
+// Expire. Replacing an unexpired item keeps its deadline. Items which would
+// never expire expire after d. Zero means unlimited.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(c *cache) {
+		c.maxLifetime = d
+	}
+}
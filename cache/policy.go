@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/thinkgos/proc/go/heap"
+	"github.com/thinkgos/proc/go/list"
+)
+
+// EvictionPolicy decides which item is evicted when a bounded cache is full.
+//
+// Insert, Remove, Reset and Victim are called while the cache holds its write
+// lock, Access may be called concurrently while the cache holds its read lock,
+// so implementations must be safe for concurrent use.
+type EvictionPolicy interface {
+	// Insert records that key was added to the cache.
+	Insert(key string)
+	// Access records that key was read or overwritten.
+	Access(key string)
+	// Remove records that key was removed from the cache.
+	Remove(key string)
+	// Reset forgets all keys.
+	Reset()
+	// Victim returns the key that should be evicted next, and false if the
+	// policy tracks no key.
+	Victim() (string, bool)
+}
+
+// NewLRUPolicy returns a policy that evicts the least recently used item.
+func NewLRUPolicy() EvictionPolicy {
+	return &listPolicy{
+		elements:     make(map[string]*list.Element[string]),
+		order:        list.New[string](),
+		moveOnAccess: true,
+	}
+}
+
+// NewFIFOPolicy returns a policy that evicts the oldest inserted item,
+// regardless of how often it was accessed.
+func NewFIFOPolicy() EvictionPolicy {
+	return &listPolicy{
+		elements:     make(map[string]*list.Element[string]),
+		order:        list.New[string](),
+		moveOnAccess: false,
+	}
+}
+
+// listPolicy keeps keys in a list, the front is the most recently inserted
+// (or accessed if moveOnAccess) key, the back is the victim.
+type listPolicy struct {
+	mu           sync.Mutex
+	elements     map[string]*list.Element[string]
+	order        *list.List[string]
+	moveOnAccess bool
+}
+
+func (p *listPolicy) Insert(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.elements[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *listPolicy) Access(key string) {
+	if !p.moveOnAccess {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.elements[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *listPolicy) Remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.elements[key]; ok {
+		p.order.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+func (p *listPolicy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elements = make(map[string]*list.Element[string])
+	p.order.Init()
+}
+
+func (p *listPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value, true
+}
+
+// NewLFUPolicy returns a policy that evicts the least frequently used item,
+// ties are broken by evicting the least recently used one.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{
+		entries: make(map[string]*lfuEntry),
+	}
+}
+
+type lfuEntry struct {
+	key   string
+	freq  uint64
+	tick  uint64 // last access, used to break ties
+	index int    // index in the heap
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].freq < h[j].freq
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(e *lfuEntry) {
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *lfuHeap) Pop() *lfuEntry {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+type lfuPolicy struct {
+	mu      sync.Mutex
+	tick    uint64
+	entries map[string]*lfuEntry
+	heap    lfuHeap
+}
+
+func (p *lfuPolicy) Insert(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.touch(key)
+}
+
+func (p *lfuPolicy) Access(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.entries[key]; ok {
+		p.touch(key)
+	}
+}
+
+func (p *lfuPolicy) touch(key string) {
+	p.tick++
+	if e, ok := p.entries[key]; ok {
+		e.freq++
+		e.tick = p.tick
+		heap.Fix(&p.heap, e.index)
+		return
+	}
+	e := &lfuEntry{key: key, freq: 1, tick: p.tick}
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfuPolicy) Remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfuPolicy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = make(map[string]*lfuEntry)
+	p.heap = nil
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
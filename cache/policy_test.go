@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"testing"
+)
+
+func Test_MaxEntriesLRU(t *testing.T) {
+	evicted := map[string]EvictReason{}
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2)).
+		OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+			evicted[k] = reason
+		})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	tc.Set("c", 3, DefaultExpiration)
+
+	if n := tc.Count(); n != 2 {
+		t.Errorf("Item count is not 2: %d", n)
+	}
+	if _, found := tc.Get("b"); found {
+		t.Error("b was found, but it should have been evicted")
+	}
+	if reason, ok := evicted["b"]; !ok || reason != EvictCapacity {
+		t.Error("b was not evicted with EvictCapacity:", reason)
+	}
+	if _, found := tc.Get("a"); !found {
+		t.Error("a was not found")
+	}
+	if _, found := tc.Get("c"); !found {
+		t.Error("c was not found")
+	}
+}
+
+func Test_MaxEntriesFIFO(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2), WithEvictionPolicy(NewFIFOPolicy))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Get("a")
+	tc.Set("a", 10, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+
+	if _, found := tc.Get("a"); found {
+		t.Error("a was found, but it should have been evicted")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b was not found")
+	}
+}
+
+func Test_MaxEntriesLFU(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(3), WithEvictionPolicy(NewLFUPolicy))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Set("c", 3, DefaultExpiration)
+	tc.Get("a")
+	tc.Get("a")
+	tc.Get("b")
+	tc.Get("c")
+	tc.Get("c")
+	tc.Set("d", 4, DefaultExpiration)
+
+	if _, found := tc.Get("b"); found {
+		t.Error("b was found, but it should have been evicted")
+	}
+	tc.Set("e", 5, DefaultExpiration)
+	if _, found := tc.Get("d"); found {
+		t.Error("d was found, but it should have been evicted")
+	}
+	for _, k := range []string{"a", "c", "e"} {
+		if _, found := tc.Get(k); !found {
+			t.Error(k, "was not found")
+		}
+	}
+}
+
+func Test_MaxEntriesDeleteAndClear(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, DefaultExpiration)
+	tc.Delete("a")
+	tc.Set("c", 3, DefaultExpiration)
+	if _, found := tc.Get("b"); !found {
+		t.Error("b was evicted although the cache was not full")
+	}
+	tc.Clear()
+	tc.Set("d", 4, DefaultExpiration)
+	tc.Set("e", 5, DefaultExpiration)
+	if n := tc.Count(); n != 2 {
+		t.Errorf("Item count is not 2: %d", n)
+	}
+}
+
+func Test_NewFromMaxEntries(t *testing.T) {
+	tc := NewFrom(DefaultExpiration, 0, map[string]Item{
+		"a": {Value: 1},
+		"b": {Value: 2},
+		"c": {Value: 3},
+	}, WithMaxEntries(2))
+	if n := tc.Count(); n != 2 {
+		t.Errorf("Item count is not 2: %d", n)
+	}
+}
+
+func Test_MaxEntryCost(t *testing.T) {
+	var reasons []EvictReason
+	tc := New(DefaultExpiration, 0, WithMaxEntryCost(3, func(_ string, x any) int64 {
+		return int64(len(x.(string)))
+	})).OnEvictedWithReason(func(_ string, _ any, reason EvictReason) {
+		reasons = append(reasons, reason)
+	})
+	tc.Set("a", "abc", DefaultExpiration)
+	if _, found := tc.Get("a"); !found {
+		t.Error("a was not found")
+	}
+	tc.Set("a", "abcd", DefaultExpiration)
+	if _, found := tc.Get("a"); found {
+		t.Error("a was found, but it exceeds the max cost")
+	}
+	// only the stored abc is evicted, abcd is rejected.
+	if len(reasons) != 1 || reasons[0] != EvictCapacity {
+		t.Error("evicted reasons are not [capacity]:", reasons)
+	}
+	if s := tc.Stats(); s.Evictions != 1 || s.Rejections != 1 {
+		t.Error("the rejection was counted as an eviction:", s)
+	}
+	if tc.SetNX("b", "abcd", DefaultExpiration) {
+		t.Error("SetNX succeeded, but b exceeds the max cost")
+	}
+}
+
+func Test_EvictReasonString(t *testing.T) {
+	tests := map[EvictReason]string{
+		EvictExpired:    "expired",
+		EvictDeleted:    "deleted",
+		EvictCleared:    "cleared",
+		EvictCapacity:   "capacity",
+		EvictReplaced:   "replaced",
+		EvictReason(-1): "unknown",
+	}
+	for reason, want := range tests {
+		if got := reason.String(); got != want {
+			t.Errorf("EvictReason(%d).String() is not %s: %s", reason, want, got)
+		}
+	}
+}
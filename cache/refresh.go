@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshFunc loads a fresh value of the key, returns the value and its
+// expiration duration, which has the same meaning as in Set.
+type RefreshFunc func(ctx context.Context, k string) (any, time.Duration, error)
+
+// WithRefreshAhead enables the stale-while-revalidate mode. An item becomes
+// stale d after it was set (the soft TTL), while its expiration (the hard TTL)
+// is still given by Set. Reading a stale item returns the old value and triggers
+// one background refresh through refresh, which sets the fresh value on success.
+// Once an item has expired it counts as missing, as usual.
+//
+// The background refresh shares the deduplication and the negative caching of
+// GetOrLoad, so at most one load of a key is in flight.
+func WithRefreshAhead(d time.Duration, refresh RefreshFunc) Option {
+	return func(c *cache) {
+		c.refreshAfter = d
+		c.refresh = refresh
+	}
+}
+
+// refreshIfStale triggers a background refresh if the item is stale.
+// It must be called without c.mu held.
+func (c *cache) refreshIfStale(k string, item Item) {
+	if item.refreshAt == 0 || c.refresh == nil || c.ctx.Err() != nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	if now <= item.refreshAt {
+		return
+	}
+
+	g := &c.loads
+	g.mu.Lock()
+	if _, ok := g.calls[k]; ok {
+		g.mu.Unlock()
+		return
+	}
+	if le, ok := g.errs[k]; ok && now <= le.expiration {
+		g.mu.Unlock()
+		return
+	}
+	cl := g.newCall(k)
+	g.mu.Unlock()
+
+	// a panic of refresh only fails the refresh, the stale value is kept.
+	go c.doLoad(c.ctx, k, cl, func(ctx context.Context) (any, time.Duration, error) {
+		return c.refresh(ctx, k)
+	}, c.Set)
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_RefreshAhead(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	tc := New(time.Minute, 0, WithRefreshAhead(20*time.Millisecond, func(_ context.Context, k string) (any, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return k + ":fresh", DefaultExpiration, nil
+	}))
+	tc.Set("foo", "stale", DefaultExpiration)
+
+	if v, _ := tc.Get("foo"); v.(string) != "stale" {
+		t.Error("foo is not stale:", v)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("refresh was called %d times before the soft ttl", n)
+	}
+
+	<-time.After(25 * time.Millisecond)
+	for range 10 {
+		if v, found := tc.Get("foo"); !found || v.(string) != "stale" {
+			t.Error("foo is not stale while refreshing:", v)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := tc.Get("foo"); v.(string) == "foo:fresh" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("foo was not refreshed")
+		}
+		<-time.After(time.Millisecond)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("refresh was called %d times", n)
+	}
+}
+
+func Test_RefreshAheadHardTTL(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithRefreshAhead(5*time.Millisecond, func(context.Context, string) (any, time.Duration, error) {
+		return "fresh", DefaultExpiration, nil
+	}))
+	tc.Set("foo", "stale", 10*time.Millisecond)
+	<-time.After(15 * time.Millisecond)
+	if _, found := tc.Get("foo"); found {
+		t.Error("foo was found after the hard ttl")
+	}
+}
+
+func Test_RefreshAheadError(t *testing.T) {
+	var calls atomic.Int32
+	tc := New(DefaultExpiration, 0,
+		WithNegativeCache(time.Minute),
+		WithRefreshAhead(time.Millisecond, func(context.Context, string) (any, time.Duration, error) {
+			calls.Add(1)
+			return nil, DefaultExpiration, errors.New("refresh failed")
+		}),
+	)
+	tc.Set("foo", "stale", DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+	tc.Get("foo")
+
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("foo was not refreshed")
+		}
+		<-time.After(time.Millisecond)
+	}
+	<-time.After(5 * time.Millisecond)
+	for range 10 {
+		if v, found := tc.Get("foo"); !found || v.(string) != "stale" {
+			t.Error("foo is not stale after the refresh failed:", v)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("refresh was called %d times, although the error is cached", n)
+	}
+}
+
+func Test_RefreshAheadPanic(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithRefreshAhead(time.Millisecond, func(context.Context, string) (any, time.Duration, error) {
+		panic("boom")
+	}))
+	tc.Set("foo", "stale", DefaultExpiration)
+	<-time.After(5 * time.Millisecond)
+	tc.Get("foo")
+
+	deadline := time.Now().Add(time.Second)
+	for tc.Stats().LoadFailures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the panicked refresh was not recorded as a failure")
+		}
+		<-time.After(time.Millisecond)
+	}
+	if v, found := tc.Get("foo"); !found || v.(string) != "stale" {
+		t.Error("foo is not stale after the refresh panicked:", v)
+	}
+}
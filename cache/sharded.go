@@ -0,0 +1,646 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"hash/maphash"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/thinkgos/proc/infra"
+)
+
+// ShardedCache is a cache that hashes keys across N independent shards, each
+// guarded by its own lock, to reduce lock contention under parallel load.
+// It exposes the same method set as Cache.
+type ShardedCache struct {
+	*shardedCache
+	// If this is confusing, see the comment at the bottom of NewFrom()
+}
+
+type shardedCache struct {
+	seed      maphash.Seed
+	shards    []*cache
+	janitor   *janitor
+	closeOnce sync.Once
+}
+
+func (sc *shardedCache) shard(k string) *cache {
+	return sc.shards[maphash.String(sc.seed, k)%uint64(len(sc.shards))]
+}
+
+// Set an item to the cache, replacing any existing item, see Cache.Set.
+func (sc *shardedCache) Set(k string, x any, d time.Duration) {
+	sc.shard(k).Set(k, x, d)
+}
+
+// SetDefault set an item to the cache, replacing any existing item, using the default
+// expiration.
+func (sc *shardedCache) SetDefault(k string, x any) {
+	sc.shard(k).SetDefault(k, x)
+}
+
+// SetNX set an item to the cache only if an item doesn't already exist for the given
+// key, or if the existing item has expired, see Cache.SetNX.
+func (sc *shardedCache) SetNX(k string, x any, d time.Duration) bool {
+	return sc.shard(k).SetNX(k, x, d)
+}
+
+// SetXX set a new value for the cache key only if it already exists, and the existing
+// item hasn't expired, see Cache.SetXX.
+func (sc *shardedCache) SetXX(k string, x any, d time.Duration) (any, bool) {
+	return sc.shard(k).SetXX(k, x, d)
+}
+
+// Upsert update or insert an item, see Cache.Upsert.
+func (sc *shardedCache) Upsert(k string, cb UpsertCb, d time.Duration) any {
+	return sc.shard(k).Upsert(k, cb, d)
+}
+
+// Get an item from the cache. Returns the item or nil, and a bool indicating
+// whether the key was found.
+func (sc *shardedCache) Get(k string) (any, bool) {
+	return sc.shard(k).Get(k)
+}
+
+// GetOrNew get an item from the cache, or set a new one returned by cb if the key
+// was not found, see Cache.GetOrNew.
+func (sc *shardedCache) GetOrNew(k string, cb InsertCb, d time.Duration) any {
+	return sc.shard(k).GetOrNew(k, cb, d)
+}
+
+// GetOrLoad get an item from the cache, or loads it using loader if the key was
+// not found, see Cache.GetOrLoad.
+func (sc *shardedCache) GetOrLoad(ctx context.Context, k string, loader LoadFunc) (any, error) {
+	return sc.shard(k).GetOrLoad(ctx, k, loader)
+}
+
+// GetEx get an item from the cache, and update it with new expires, see Cache.GetEx.
+func (sc *shardedCache) GetEx(k string, d time.Duration) (any, bool) {
+	return sc.shard(k).GetEx(k, d)
+}
+
+// GetDel get an item from the cache the delete it from the cache, see Cache.GetDel.
+func (sc *shardedCache) GetDel(k string) (any, bool) {
+	return sc.shard(k).GetDel(k)
+}
+
+// GetWithExpiration returns an item and its expiration time from the cache,
+// see Cache.GetWithExpiration.
+func (sc *shardedCache) GetWithExpiration(k string) (any, time.Time, bool) {
+	return sc.shard(k).GetWithExpiration(k)
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (sc *shardedCache) Delete(k string) {
+	sc.shard(k).Delete(k)
+}
+
+// Delete all expired items from the cache.
+func (sc *shardedCache) DeleteExpired() {
+	for _, c := range sc.shards {
+		c.DeleteExpired()
+	}
+}
+
+// Clear all items from the cache.
+func (sc *shardedCache) Clear() {
+	for _, c := range sc.shards {
+		c.Clear()
+	}
+}
+
+// Expire update with new expires if key found, see Cache.Expire.
+func (sc *shardedCache) Expire(k string, d time.Duration) bool {
+	return sc.shard(k).Expire(k, d)
+}
+
+// Write the cache's items (using Gob) to an io.Writer.
+//
+// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
+// documentation for NewFrom().)
+func (sc *shardedCache) Save(w io.Writer) error {
+	return saveItems(w, sc.Items())
+}
+
+// Save the cache's items to the given filename, creating the file if it
+// doesn't exist, and overwriting it if it does.
+//
+// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
+// documentation for NewFrom().)
+func (sc *shardedCache) SaveFile(fname string) error {
+	fp, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	err = sc.Save(fp)
+	if err != nil {
+		_ = fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// Add (Gob-serialized) cache items from an io.Reader, excluding any items with
+// keys that already exist (and haven't expired) in the current cache.
+//
+// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
+// documentation for NewFrom().)
+func (sc *shardedCache) Load(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	items := map[string]Item{}
+	err := dec.Decode(&items)
+	if err == nil {
+		shardItems := make([]map[string]Item, len(sc.shards))
+		for k, v := range items {
+			i := maphash.String(sc.seed, k) % uint64(len(sc.shards))
+			if shardItems[i] == nil {
+				shardItems[i] = make(map[string]Item)
+			}
+			shardItems[i][k] = v
+		}
+		for i, m := range shardItems {
+			if m != nil {
+				sc.shards[i].load(m)
+			}
+		}
+	}
+	return err
+}
+
+// Load and add cache items from the given filename, excluding any items with
+// keys that already exist in the current cache.
+//
+// NOTE: This method is deprecated in favor of c.Items() and NewFrom() (see the
+// documentation for NewFrom().)
+func (sc *shardedCache) LoadFile(fname string) error {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	err = sc.Load(fp)
+	if err != nil {
+		_ = fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// Items Copies all unexpired items in the cache into a new map and returns it.
+func (sc *shardedCache) Items() map[string]Item {
+	m := make(map[string]Item)
+	for _, c := range sc.shards {
+		for k, v := range c.Items() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Count returns the number of items in the cache. This may include items that have
+// expired, but have not yet been cleaned up.
+func (sc *shardedCache) Count() int {
+	n := 0
+	for _, c := range sc.shards {
+		n += c.Count()
+	}
+	return n
+}
+
+// All returns an iterator over all unexpired items in the cache, shard by
+// shard, with only the shard being iterated read locked, see Cache.All.
+func (sc *shardedCache) All() iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for _, c := range sc.shards {
+			for k, v := range c.All() {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Scan returns up to count unexpired keys matching match, in lexical order
+// across all shards, starting at cursor, see Cache.Scan.
+func (sc *shardedCache) Scan(match, cursor string, count int) (keys []string, next string) {
+	s := newKeySelector(count)
+	matches := keyMatcher(match)
+	for _, c := range sc.shards {
+		c.scanKeys(matches, cursor, s)
+	}
+	return s.page()
+}
+
+// DeletePrefix deletes all items whose key starts with prefix, see Cache.DeletePrefix.
+func (sc *shardedCache) DeletePrefix(prefix string) int {
+	n := 0
+	for _, c := range sc.shards {
+		n += c.DeletePrefix(prefix)
+	}
+	return n
+}
+
+// GetMany gets the items of the keys from the cache, under one lock per shard,
+// see Cache.GetMany.
+func (sc *shardedCache) GetMany(keys ...string) map[string]any {
+	m := make(map[string]any, len(keys))
+	for c, ks := range sc.groupKeys(keys) {
+		maps.Copy(m, c.GetMany(ks...))
+	}
+	return m
+}
+
+// SetMany sets all the items to the cache, under one lock per shard, see Cache.SetMany.
+func (sc *shardedCache) SetMany(items map[string]any, d time.Duration) {
+	shards := make(map[*cache]map[string]any)
+	for k, x := range items {
+		c := sc.shard(k)
+		if shards[c] == nil {
+			shards[c] = make(map[string]any)
+		}
+		shards[c][k] = x
+	}
+	for c, m := range shards {
+		c.SetMany(m, d)
+	}
+}
+
+// DeleteMany deletes the items of the keys from the cache, under one lock per
+// shard, see Cache.DeleteMany.
+func (sc *shardedCache) DeleteMany(keys ...string) {
+	for c, ks := range sc.groupKeys(keys) {
+		c.DeleteMany(ks...)
+	}
+}
+
+// TTL returns the remaining time to live of an item, see Cache.TTL.
+func (sc *shardedCache) TTL(k string) (time.Duration, bool) {
+	return sc.shard(k).TTL(k)
+}
+
+func (sc *shardedCache) groupKeys(keys []string) map[*cache][]string {
+	shards := make(map[*cache][]string)
+	for _, k := range keys {
+		c := sc.shard(k)
+		shards[c] = append(shards[c], k)
+	}
+	return shards
+}
+
+// Watch returns a channel receiving the events of the keys matching
+// keyOrPrefix in any shard, see Cache.Watch. The events of a key are in
+// order, the events of keys in different shards are not.
+func (sc *shardedCache) Watch(keyOrPrefix string) (<-chan Event, func()) {
+	w := newWatcher(keyOrPrefix, sc.shards[0].watchBuffer)
+	for _, c := range sc.shards {
+		c.addWatcher(w)
+	}
+	return w.ch, func() {
+		for _, c := range sc.shards {
+			c.removeWatcher(w)
+		}
+		w.close()
+	}
+}
+
+// SetWithTags set an item to the cache with tags, see Cache.SetWithTags.
+func (sc *shardedCache) SetWithTags(k string, x any, d time.Duration, tags ...string) {
+	sc.shard(k).SetWithTags(k, x, d, tags...)
+}
+
+// InvalidateTag deletes all items carrying the tag in any shard, see Cache.InvalidateTag.
+func (sc *shardedCache) InvalidateTag(tag string) int {
+	n := 0
+	for _, c := range sc.shards {
+		n += c.InvalidateTag(tag)
+	}
+	return n
+}
+
+// Bytes returns the total size of the items in all shards, see Cache.Bytes.
+// The budget of WithMaxBytes applies per shard.
+func (sc *shardedCache) Bytes() infra.ByteSize {
+	var n infra.ByteSize
+	for _, c := range sc.shards {
+		n += c.Bytes()
+	}
+	return n
+}
+
+// WriteSnapshot writes all unexpired items of the cache to w, see Cache.WriteSnapshot.
+func (sc *shardedCache) WriteSnapshot(w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, sc.Items())
+}
+
+// ReadSnapshot adds the items read from a snapshot, see Cache.ReadSnapshot.
+func (sc *shardedCache) ReadSnapshot(r io.Reader, codec Codec) (int, error) {
+	return readSnapshot(r, codec, sc.setIfAbsent)
+}
+
+// SaveSnapshot writes a snapshot of the cache to the given filename atomically,
+// see Cache.SaveSnapshot.
+func (sc *shardedCache) SaveSnapshot(fname string, codec Codec) error {
+	return saveSnapshotFile(fname, codec, sc.Items())
+}
+
+// LoadSnapshot adds the items read from the snapshot file, see Cache.ReadSnapshot.
+func (sc *shardedCache) LoadSnapshot(fname string, codec Codec) (int, error) {
+	return loadSnapshotFile(fname, codec, sc.setIfAbsent)
+}
+
+// AutoSnapshot saves a snapshot of the cache to the given filename every
+// interval, see Cache.AutoSnapshot.
+func (sc *shardedCache) AutoSnapshot(fname string, codec Codec, interval time.Duration, onError func(error)) (stop func() error) {
+	return autoSnapshot(interval, onError, func() error {
+		return sc.SaveSnapshot(fname, codec)
+	})
+}
+
+func (sc *shardedCache) setIfAbsent(k string, item Item) bool {
+	return sc.shard(k).setIfAbsent(k, item)
+}
+
+// Stats returns a snapshot of the statistics summed over all shards.
+func (sc *shardedCache) Stats() Stats {
+	var s Stats
+	for _, c := range sc.shards {
+		s = s.add(c.Stats())
+	}
+	return s
+}
+
+// Incr increment an item of any number type by n, see Cache.Incr.
+func (sc *shardedCache) Incr(k string, n int64) error {
+	return sc.shard(k).Incr(k, n)
+}
+
+// IncrFloat increment an item of type float32 or float64 by n, see Cache.IncrFloat.
+func (sc *shardedCache) IncrFloat(k string, n float64) error {
+	return sc.shard(k).IncrFloat(k, n)
+}
+
+// Decr decrement an item of any number type by n, see Cache.Decr.
+func (sc *shardedCache) Decr(k string, n int64) error {
+	return sc.shard(k).Decr(k, n)
+}
+
+// DecrFloat decrement an item of type float32 or float64 by n, see Cache.DecrFloat.
+func (sc *shardedCache) DecrFloat(k string, n float64) error {
+	return sc.shard(k).DecrFloat(k, n)
+}
+
+// IncrInt increment an item of type int by n, and returns the incremented value.
+func (sc *shardedCache) IncrInt(k string, n int) (int, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrInt8 increment an item of type int8 by n, and returns the incremented value.
+func (sc *shardedCache) IncrInt8(k string, n int8) (int8, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrInt16 increment an item of type int16 by n, and returns the incremented value.
+func (sc *shardedCache) IncrInt16(k string, n int16) (int16, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrInt32 increment an item of type int32 by n, and returns the incremented value.
+func (sc *shardedCache) IncrInt32(k string, n int32) (int32, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrInt64 increment an item of type int64 by n, and returns the incremented value.
+func (sc *shardedCache) IncrInt64(k string, n int64) (int64, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUint increment an item of type uint by n, and returns the incremented value.
+func (sc *shardedCache) IncrUint(k string, n uint) (uint, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUintptr increment an item of type uintptr by n, and returns the incremented value.
+func (sc *shardedCache) IncrUintptr(k string, n uintptr) (uintptr, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUint8 increment an item of type uint8 by n, and returns the incremented value.
+func (sc *shardedCache) IncrUint8(k string, n uint8) (uint8, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUint16 increment an item of type uint16 by n, and returns the incremented value.
+func (sc *shardedCache) IncrUint16(k string, n uint16) (uint16, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUint32 increment an item of type uint32 by n, and returns the incremented value.
+func (sc *shardedCache) IncrUint32(k string, n uint32) (uint32, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrUint64 increment an item of type uint64 by n, and returns the incremented value.
+func (sc *shardedCache) IncrUint64(k string, n uint64) (uint64, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrFloat32 increment an item of type float32 by n, and returns the incremented value.
+func (sc *shardedCache) IncrFloat32(k string, n float32) (float32, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// IncrFloat64 increment an item of type float64 by n, and returns the incremented value.
+func (sc *shardedCache) IncrFloat64(k string, n float64) (float64, error) {
+	return incr(sc.shard(k), k, n)
+}
+
+// DecrInt decrement an item of type int by n, and returns the decremented value.
+func (sc *shardedCache) DecrInt(k string, n int) (int, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrInt8 decrement an item of type int8 by n, and returns the decremented value.
+func (sc *shardedCache) DecrInt8(k string, n int8) (int8, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrInt16 decrement an item of type int16 by n, and returns the decremented value.
+func (sc *shardedCache) DecrInt16(k string, n int16) (int16, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrInt32 decrement an item of type int32 by n, and returns the decremented value.
+func (sc *shardedCache) DecrInt32(k string, n int32) (int32, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrInt64 decrement an item of type int64 by n, and returns the decremented value.
+func (sc *shardedCache) DecrInt64(k string, n int64) (int64, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUint decrement an item of type uint by n, and returns the decremented value.
+func (sc *shardedCache) DecrUint(k string, n uint) (uint, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUintptr decrement an item of type uintptr by n, and returns the decremented value.
+func (sc *shardedCache) DecrUintptr(k string, n uintptr) (uintptr, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUint8 decrement an item of type uint8 by n, and returns the decremented value.
+func (sc *shardedCache) DecrUint8(k string, n uint8) (uint8, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUint16 decrement an item of type uint16 by n, and returns the decremented value.
+func (sc *shardedCache) DecrUint16(k string, n uint16) (uint16, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUint32 decrement an item of type uint32 by n, and returns the decremented value.
+func (sc *shardedCache) DecrUint32(k string, n uint32) (uint32, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrUint64 decrement an item of type uint64 by n, and returns the decremented value.
+func (sc *shardedCache) DecrUint64(k string, n uint64) (uint64, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrFloat32 decrement an item of type float32 by n, and returns the decremented value.
+func (sc *shardedCache) DecrFloat32(k string, n float32) (float32, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// DecrFloat64 decrement an item of type float64 by n, and returns the decremented value.
+func (sc *shardedCache) DecrFloat64(k string, n float64) (float64, error) {
+	return decr(sc.shard(k), k, n)
+}
+
+// IncrBy increment an item of an integer type by n, creating it if missing,
+// see Cache.IncrBy.
+func (sc *shardedCache) IncrBy(k string, n int64, d time.Duration) (int64, error) {
+	return sc.shard(k).IncrBy(k, n, d)
+}
+
+// DecrBy decrement an item of an integer type by n, see Cache.DecrBy.
+func (sc *shardedCache) DecrBy(k string, n int64, d time.Duration) (int64, error) {
+	return sc.shard(k).DecrBy(k, n, d)
+}
+
+// IncrByClamp increment an item of an integer type by n, clamped to [lo, hi],
+// see Cache.IncrByClamp.
+func (sc *shardedCache) IncrByClamp(k string, n, lo, hi int64, d time.Duration) (int64, error) {
+	return sc.shard(k).IncrByClamp(k, n, lo, hi, d)
+}
+
+// IncrByFloat increment an item of type float32 or float64 by n, creating it
+// if missing, see Cache.IncrByFloat.
+func (sc *shardedCache) IncrByFloat(k string, n float64, d time.Duration) (float64, error) {
+	return sc.shard(k).IncrByFloat(k, n, d)
+}
+
+// DecrByFloat decrement an item of type float32 or float64 by n, see Cache.DecrByFloat.
+func (sc *shardedCache) DecrByFloat(k string, n float64, d time.Duration) (float64, error) {
+	return sc.shard(k).DecrByFloat(k, n, d)
+}
+
+// CompareAndSwap swaps the value of an item for new if the value is equal to
+// old, see Cache.CompareAndSwap.
+func (sc *shardedCache) CompareAndSwap(k string, old, new any) bool {
+	return sc.shard(k).CompareAndSwap(k, old, new)
+}
+
+// GetSet set an item to the cache, and returns the previous value, see Cache.GetSet.
+func (sc *shardedCache) GetSet(k string, x any, d time.Duration) (any, bool) {
+	return sc.shard(k).GetSet(k, x, d)
+}
+
+func stopShardedJanitor(sc *ShardedCache) {
+	sc.janitor.Stop()
+}
+
+// close stops the janitor, and closes every shard, see cache.close.
+func (sc *shardedCache) close() {
+	sc.closeOnce.Do(func() {
+		if sc.janitor != nil {
+			sc.janitor.Stop()
+		}
+	})
+	for _, c := range sc.shards {
+		c.close()
+	}
+}
+
+// NewSharded return a new cache which hashes keys across the given number of
+// shards, with a given default expiration duration and cleanup interval,
+// see New(). The options apply to every shard, so limits such as
+// WithMaxEntries are per shard.
+func NewSharded(shards int, defaultExpiration, cleanupInterval time.Duration, opts ...Option) *ShardedCache {
+	return newSharded(context.Background(), shards, defaultExpiration, cleanupInterval, opts...)
+}
+
+// NewShardedWithContext return a new sharded cache like NewSharded(), which is
+// closed when ctx is done, see NewWithContext.
+func NewShardedWithContext(ctx context.Context, shards int, defaultExpiration, cleanupInterval time.Duration, opts ...Option) *ShardedCache {
+	return newSharded(ctx, shards, defaultExpiration, cleanupInterval, opts...)
+}
+
+func newSharded(ctx context.Context, shards int, defaultExpiration, cleanupInterval time.Duration, opts ...Option) *ShardedCache {
+	if shards < 1 {
+		shards = 1
+	}
+	sc := &shardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*cache, shards),
+	}
+	for i := range sc.shards {
+		sc.shards[i] = newCache(ctx, defaultExpiration, make(map[string]Item), opts...)
+	}
+	// See the comment at the bottom of NewFrom().
+	SC := &ShardedCache{sc}
+	if cleanupInterval > 0 {
+		sc.janitor = runJanitor(sc.DeleteExpired, cleanupInterval)
+		runtime.SetFinalizer(SC, stopShardedJanitor)
+	}
+	if ctx.Done() != nil {
+		context.AfterFunc(ctx, sc.close)
+	}
+	return SC
+}
+
+// Close stops the janitor and the background refreshes of all shards,
+// see Cache.Close.
+func (sc *ShardedCache) Close() error {
+	runtime.SetFinalizer(sc, nil)
+	sc.close()
+	return nil
+}
+
+// OnEvicted sets an (optional) function that is called with the key and value
+// when an item is evicted from any shard, see Cache.OnEvicted.
+func (sc *ShardedCache) OnEvicted(f func(string, any)) *ShardedCache {
+	onEvicted := ignoreReason(f)
+	for _, c := range sc.shards {
+		c.setOnEvicted(onEvicted, false)
+	}
+	return sc
+}
+
+// OnEvictedWithReason sets an (optional) function that is called with the key,
+// value and the reason when an item is evicted from any shard,
+// see Cache.OnEvictedWithReason.
+func (sc *ShardedCache) OnEvictedWithReason(f func(string, any, EvictReason)) *ShardedCache {
+	for _, c := range sc.shards {
+		c.setOnEvicted(f, true)
+	}
+	return sc
+}
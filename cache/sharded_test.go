@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ShardedCache(t *testing.T) {
+	tc := NewSharded(13, DefaultExpiration, 0)
+	for i := range 100 {
+		tc.Set("key"+strconv.Itoa(i), i, DefaultExpiration)
+	}
+	for i := range 100 {
+		x, found := tc.Get("key" + strconv.Itoa(i))
+		if !found {
+			t.Fatal("key", i, "was not found")
+		}
+		if x.(int) != i {
+			t.Error("key", i, "is not", i, x)
+		}
+	}
+	if n := tc.Count(); n != 100 {
+		t.Errorf("Item count is not 100: %d", n)
+	}
+	if n := len(tc.Items()); n != 100 {
+		t.Errorf("Items length is not 100: %d", n)
+	}
+
+	if tc.SetNX("key1", 0, DefaultExpiration) {
+		t.Error("Successfully added another key1")
+	}
+	if old, ok := tc.SetXX("key1", 11, DefaultExpiration); !ok || old.(int) != 1 {
+		t.Error("Couldn't replace existing key1:", old)
+	}
+	if v, err := tc.IncrInt("key1", 2); err != nil || v != 13 {
+		t.Error("key1 is not 13:", v, err)
+	}
+	if v, err := tc.DecrInt("key1", 3); err != nil || v != 10 {
+		t.Error("key1 is not 10:", v, err)
+	}
+	if v, found := tc.GetDel("key1"); !found || v.(int) != 10 {
+		t.Error("GetDel key1 is not 10:", v)
+	}
+	tc.Delete("key2")
+	if _, found := tc.Get("key2"); found {
+		t.Error("key2 was found, but it should have been deleted")
+	}
+
+	tc.Clear()
+	if n := tc.Count(); n != 0 {
+		t.Errorf("Item count is not 0: %d", n)
+	}
+}
+
+func Test_ShardedCacheTimes(t *testing.T) {
+	tc := NewSharded(4, 50*time.Millisecond, 1*time.Millisecond)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	tc.Set("c", 3, 20*time.Millisecond)
+
+	<-time.After(25 * time.Millisecond)
+	if _, found := tc.Get("c"); found {
+		t.Error("Found c when it should have been automatically deleted")
+	}
+	<-time.After(30 * time.Millisecond)
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a when it should have been automatically deleted")
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("Did not find b even though it was set to never expire")
+	}
+}
+
+func Test_ShardedCacheOnEvicted(t *testing.T) {
+	var mu sync.Mutex
+	evicted := map[string]EvictReason{}
+	tc := NewSharded(4, DefaultExpiration, 0).
+		OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+			mu.Lock()
+			evicted[k] = reason
+			mu.Unlock()
+		})
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, time.Millisecond)
+	tc.Delete("a")
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	if evicted["a"] != EvictDeleted {
+		t.Error("a was not evicted with EvictDeleted:", evicted["a"])
+	}
+	if reason, ok := evicted["b"]; !ok || reason != EvictExpired {
+		t.Error("b was not evicted with EvictExpired:", reason)
+	}
+}
+
+func Test_ShardedCacheSerialization(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+	tc.Set("b", "b", DefaultExpiration)
+	tc.Set("*struct", &TestStruct{Num: 1}, DefaultExpiration)
+
+	fp := &bytes.Buffer{}
+	if err := tc.Save(fp); err != nil {
+		t.Fatal("Couldn't save cache to fp:", err)
+	}
+	oc := NewSharded(7, DefaultExpiration, 0)
+	oc.Set("a", "aa", DefaultExpiration) // this should not be overwritten
+	if err := oc.Load(fp); err != nil {
+		t.Fatal("Couldn't load cache from fp:", err)
+	}
+	if a, _ := oc.Get("a"); a.(string) != "aa" {
+		t.Error("a was overwritten")
+	}
+	if b, _ := oc.Get("b"); b.(string) != "b" {
+		t.Error("b is not b")
+	}
+	if s, _ := oc.Get("*struct"); s.(*TestStruct).Num != 1 {
+		t.Error("*struct.Num is not 1")
+	}
+}
+
+func BenchmarkShardedCacheGetExpiring(b *testing.B) {
+	benchmarkShardedCacheGet(b, 5*time.Minute)
+}
+
+func BenchmarkShardedCacheGetNotExpiring(b *testing.B) {
+	benchmarkShardedCacheGet(b, NoExpiration)
+}
+
+func benchmarkShardedCacheGet(b *testing.B, exp time.Duration) {
+	b.StopTimer()
+	tc := NewSharded(10, exp, 0)
+	tc.Set("foobarba", "zquux", DefaultExpiration)
+	b.StartTimer()
+	for b.Loop() {
+		tc.Get("foobarba")
+	}
+}
+
+func BenchmarkShardedCacheGetManyConcurrentExpiring(b *testing.B) {
+	benchmarkShardedCacheGetManyConcurrent(b, 5*time.Minute)
+}
+
+func BenchmarkShardedCacheGetManyConcurrentNotExpiring(b *testing.B) {
+	benchmarkShardedCacheGetManyConcurrent(b, NoExpiration)
+}
+
+func benchmarkShardedCacheGetManyConcurrent(b *testing.B, exp time.Duration) {
+	b.StopTimer()
+	n := 10000
+	tsc := NewSharded(20, exp, 0)
+	keys := make([]string, n)
+	for i := range n {
+		k := "foo" + strconv.Itoa(i)
+		keys[i] = k
+		tsc.Set(k, "bar", DefaultExpiration)
+	}
+	each := b.N / n
+	wg := new(sync.WaitGroup)
+	wg.Add(n)
+	for _, v := range keys {
+		go func(k string) {
+			for range each {
+				tsc.Get(k)
+			}
+			wg.Done()
+		}(v)
+	}
+	b.StartTimer()
+	wg.Wait()
+}
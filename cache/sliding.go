@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"time"
+)
+
+// rlock locks c.mu for a read, which renews the expiration of the item read
+// with WithSlidingExpiration, so the write lock is taken then.
+func (c *cache) rlock() {
+	if c.sliding {
+		c.mu.Lock()
+	} else {
+		c.mu.RLock()
+	}
+}
+
+// runlock unlocks c.mu locked by rlock.
+func (c *cache) runlock() {
+	if c.sliding {
+		c.mu.Unlock()
+	} else {
+		c.mu.RUnlock()
+	}
+}
+
+// access returns the unexpired item read, recording the access to the
+// eviction policy, and renewing its expiration with WithSlidingExpiration.
+// It must be called with c.mu held by rlock.
+func (c *cache) access(k string) (Item, bool) {
+	item, found := c.getItem(k)
+	if !found {
+		return item, false
+	}
+	c.touch(k)
+	if c.sliding && item.ttl > 0 {
+		e := time.Now().Add(item.ttl).UnixNano()
+		if item.deadline > 0 && e > item.deadline {
+			e = item.deadline
+		}
+		item.Expiration = e
+		c.items[k] = item
+		c.expiry.set(k, e)
+	}
+	return item, true
+}
+
+// renew sets the expiration of an existing item, capped by its deadline, and
+// returns the item. It must be called with c.mu held.
+func (c *cache) renew(k string, item Item, e int64) Item {
+	if c.sliding {
+		item.ttl = 0
+		if e > 0 {
+			item.ttl = time.Duration(e - time.Now().UnixNano())
+		}
+	}
+	if item.deadline > 0 && (e <= 0 || e > item.deadline) {
+		e = item.deadline
+	}
+	item.Expiration = e
+	c.items[k] = item
+	c.expiry.set(k, e)
+	return item
+}
+
+// setLifetime sets the ttl renewed on access and the deadline of an item to
+// set, and caps its expiration by the deadline. An item which replaces an
+// unexpired item keeps its deadline, and its ttl if the expiration is kept,
+// e.g. by Upsert. It must be called with c.mu held.
+func (c *cache) setLifetime(k string, item *Item) {
+	now := time.Now().UnixNano()
+	old, found := c.items[k]
+	alive := found && !old.Expired()
+	if c.sliding {
+		switch {
+		case alive && item.Expiration == old.Expiration:
+			item.ttl = old.ttl
+		case item.Expiration > 0:
+			item.ttl = time.Duration(item.Expiration - now)
+		default:
+			item.ttl = 0
+		}
+	}
+	if c.maxLifetime > 0 {
+		if alive && old.deadline > 0 {
+			item.deadline = old.deadline
+		} else {
+			item.deadline = now + int64(c.maxLifetime)
+		}
+		if item.Expiration <= 0 || item.Expiration > item.deadline {
+			item.Expiration = item.deadline
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_SlidingExpiration(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithSlidingExpiration())
+	tc.Set("session", "s", 200*time.Millisecond)
+	tc.Set("forever", "f", NoExpiration)
+	tc.Set("idle", "i", 200*time.Millisecond)
+
+	for range 6 {
+		<-time.After(50 * time.Millisecond)
+		if _, found := tc.Get("session"); !found {
+			t.Fatal("session expired while it was read")
+		}
+	}
+	if _, found := tc.Get("idle"); found {
+		t.Error("idle did not expire")
+	}
+	if _, e, found := tc.GetWithExpiration("forever"); !found || !e.IsZero() {
+		t.Error("forever got an expiration:", e)
+	}
+
+	// Upsert keeps the idle timeout of the item.
+	tc.Upsert("session", func(bool, any) any { return "s2" }, DefaultExpiration)
+	<-time.After(50 * time.Millisecond)
+	tc.Get("session")
+	if d, _ := tc.TTL("session"); d <= 100*time.Millisecond {
+		t.Error("Upsert did not keep the idle timeout:", d)
+	}
+}
+
+func Test_MaxLifetime(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithSlidingExpiration(), WithMaxLifetime(300*time.Millisecond))
+	tc.Set("session", "s", 200*time.Millisecond)
+	tc.Set("forever", "f", NoExpiration)
+
+	if d, _ := tc.TTL("forever"); d <= 0 || d > 300*time.Millisecond {
+		t.Error("forever is not capped by the max lifetime:", d)
+	}
+	<-time.After(150 * time.Millisecond)
+	tc.Set("session", "s2", 200*time.Millisecond) // keeps the deadline
+	if _, found := tc.GetEx("session", time.Minute); !found {
+		t.Fatal("session was not found")
+	}
+	if d, _ := tc.TTL("session"); d > 150*time.Millisecond {
+		t.Error("GetEx extended session over the max lifetime:", d)
+	}
+	if tc.Expire("session", time.Minute); tc.expiry.heap.Len() != 2 {
+		t.Error("expiry index is not consistent:", tc.expiry.heap.Len())
+	}
+	<-time.After(200 * time.Millisecond)
+	if _, found := tc.Get("session"); found {
+		t.Error("session outlived the max lifetime")
+	}
+
+	// a new item starts a new lifetime.
+	tc.Set("session", "s3", 200*time.Millisecond)
+	if d, _ := tc.TTL("session"); d <= 150*time.Millisecond {
+		t.Error("session did not start a new lifetime:", d)
+	}
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/thinkgos/proc/internal/record"
+)
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+const SnapshotVersion = 1
+
+// snapshotMagic starts every snapshot.
+const snapshotMagic = "PCSN"
+
+var (
+	ErrSnapshotFormat  = errors.New("cache: invalid snapshot format")
+	ErrSnapshotVersion = errors.New("cache: unsupported snapshot version")
+	ErrSnapshotCodec   = errors.New("cache: snapshot codec mismatch")
+)
+
+// Codec encodes and decodes the values of the cache for snapshots.
+type Codec interface {
+	// Name identifies the codec. It is written to the snapshot header, and
+	// a snapshot can only be read with a codec of the same name.
+	Name() string
+	// Marshal encodes the value.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes the value.
+	Unmarshal(data []byte) (any, error)
+}
+
+// JSONCodec encodes values of type T with encoding/json, values are decoded as T.
+type JSONCodec[T any] struct{}
+
+// Name implements Codec.
+func (JSONCodec[T]) Name() string { return "json" }
+
+// Marshal implements Codec.
+func (JSONCodec[T]) Marshal(v any) ([]byte, error) {
+	if _, ok := v.(T); !ok {
+		return nil, valueTypeError[T](v)
+	}
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec.
+func (JSONCodec[T]) Unmarshal(data []byte) (any, error) {
+	var v T
+
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec encodes values of type T with encoding/gob, values are decoded as T.
+// As the type is known, there is no need to gob.Register it unless T is an
+// interface type.
+type GobCodec[T any] struct{}
+
+// Name implements Codec.
+func (GobCodec[T]) Name() string { return "gob" }
+
+// Marshal implements Codec.
+func (GobCodec[T]) Marshal(v any) ([]byte, error) {
+	vv, ok := v.(T)
+	if !ok {
+		return nil, valueTypeError[T](v)
+	}
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(&vv)
+	return b.Bytes(), err
+}
+
+// Unmarshal implements Codec.
+func (GobCodec[T]) Unmarshal(data []byte) (any, error) {
+	var v T
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+func valueTypeError[T any](v any) error {
+	return fmt.Errorf("cache: value type %T is not %T", v, *new(T))
+}
+
+// WriteSnapshot writes all unexpired items of the cache to w, with the values
+// encoded by codec. The snapshot starts with a versioned header naming codec.
+func (c *cache) WriteSnapshot(w io.Writer, codec Codec) error {
+	return writeSnapshot(w, codec, c.Items())
+}
+
+// ReadSnapshot adds the items read from a snapshot written by WriteSnapshot,
+// excluding any items which have expired, or with keys that already exist
+// (and haven't expired) in the current cache. The snapshot is read streamingly,
+// one item at a time. Returns the number of items added.
+func (c *cache) ReadSnapshot(r io.Reader, codec Codec) (int, error) {
+	return readSnapshot(r, codec, c.setIfAbsent)
+}
+
+// SaveSnapshot writes a snapshot of the cache to the given filename atomically:
+// the snapshot is written to a temporary file in the same directory, which
+// then replaces the file.
+func (c *cache) SaveSnapshot(fname string, codec Codec) error {
+	return saveSnapshotFile(fname, codec, c.Items())
+}
+
+// LoadSnapshot adds the items read from the snapshot file, see ReadSnapshot.
+func (c *cache) LoadSnapshot(fname string, codec Codec) (int, error) {
+	return loadSnapshotFile(fname, codec, c.setIfAbsent)
+}
+
+// AutoSnapshot saves a snapshot of the cache to the given filename every
+// interval, see SaveSnapshot. Errors are reported to onError if not nil.
+// The returned stop function stops saving and then saves a last snapshot,
+// so that a process can warm its cache with LoadSnapshot on restart. If the
+// interval is less than one, only the last snapshot is saved.
+func (c *cache) AutoSnapshot(fname string, codec Codec, interval time.Duration, onError func(error)) (stop func() error) {
+	return autoSnapshot(interval, onError, func() error {
+		return c.SaveSnapshot(fname, codec)
+	})
+}
+
+// setIfAbsent adds the item if the key does not exist or has expired.
+// Returns true if the item was added.
+func (c *cache) setIfAbsent(k string, item Item) bool {
+	c.mu.Lock()
+	_, found := c.getValue(k)
+	ok := !found && c.set(k, item)
+	c.unlock()
+	return ok
+}
+
+func autoSnapshot(interval time.Duration, onError func(error), save func() error) func() error {
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var tick <-chan time.Time // nil, never fires, if disabled
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-tick:
+				if err := save(); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			close(stopCh)
+			<-done
+			err = save()
+		})
+		return err
+	}
+}
+
+func saveSnapshotFile(fname string, codec Codec, items map[string]Item) (err error) {
+	fp, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = fp.Close()
+			_ = os.Remove(fp.Name())
+		}
+	}()
+	if err = writeSnapshot(fp, codec, items); err != nil {
+		return err
+	}
+	if err = fp.Sync(); err != nil {
+		return err
+	}
+	if err = fp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fp.Name(), fname)
+}
+
+func loadSnapshotFile(fname string, codec Codec, add func(string, Item) bool) (int, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	n, err := readSnapshot(fp, codec, add)
+	if err != nil {
+		_ = fp.Close()
+		return n, err
+	}
+	return n, fp.Close()
+}
+
+// writeSnapshot writes the snapshot in the record format (see internal/record):
+//
+//	header: magic "PCSN" | version uvarint | codec name
+//	entry:  recordEntry | key | expiration varint | value
+//	end:    recordEnd | number of entries uvarint
+//
+// where strings and values are written as uvarint length followed by the bytes.
+func writeSnapshot(w io.Writer, codec Codec, items map[string]Item) error {
+	rw := record.NewWriter(w, snapshotMagic, SnapshotVersion, codec.Name())
+	now := time.Now().UnixNano()
+	for k, v := range items {
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		data, err := codec.Marshal(v.Value)
+		if err != nil {
+			return fmt.Errorf("cache: snapshot key %q: %w", k, err)
+		}
+		rw.Entry()
+		rw.String(k)
+		rw.Varint(v.Expiration)
+		rw.Bytes(data)
+	}
+	return rw.End()
+}
+
+// readSnapshot reads the snapshot written by writeSnapshot, and calls add for
+// every unexpired item. Returns the number of items add accepted.
+func readSnapshot(r io.Reader, codec Codec, add func(string, Item) bool) (int, error) {
+	rr := record.NewReader(r, snapshotMagic, ErrSnapshotFormat)
+	version, err := rr.Version()
+	if err != nil {
+		return 0, err
+	}
+	if version != SnapshotVersion {
+		return 0, fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+	name, err := rr.String()
+	if err != nil {
+		return 0, err
+	}
+	if name != codec.Name() {
+		return 0, fmt.Errorf("%w: snapshot %q, codec %q", ErrSnapshotCodec, name, codec.Name())
+	}
+
+	added := 0
+	for {
+		more, err := rr.Next()
+		if err != nil || !more {
+			return added, err
+		}
+		key, err := rr.String()
+		if err != nil {
+			return added, err
+		}
+		expiration, err := rr.Varint()
+		if err != nil {
+			return added, err
+		}
+		data, err := rr.Bytes()
+		if err != nil {
+			return added, err
+		}
+		if expiration > 0 && time.Now().UnixNano() > expiration {
+			continue
+		}
+		value, err := codec.Unmarshal(data)
+		if err != nil {
+			return added, fmt.Errorf("cache: snapshot key %q: %w", key, err)
+		}
+		if add(key, Item{Value: value, Expiration: expiration}) {
+			added++
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type upperCodec struct{}
+
+func (upperCodec) Name() string                       { return "upper" }
+func (upperCodec) Marshal(v any) ([]byte, error)      { return bytes.ToUpper([]byte(v.(string))), nil }
+func (upperCodec) Unmarshal(data []byte) (any, error) { return string(data), nil }
+
+func Test_Snapshot(t *testing.T) {
+	codecs := []Codec{
+		JSONCodec[*TestStruct]{},
+		GobCodec[*TestStruct]{},
+	}
+	for _, codec := range codecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			tc := New(DefaultExpiration, 0)
+			tc.Set("a", &TestStruct{Num: 1}, DefaultExpiration)
+			tc.Set("b", &TestStruct{Num: 2, Children: []*TestStruct{{Num: 3}}}, time.Minute)
+			tc.Set("expired", &TestStruct{Num: 4}, time.Millisecond)
+			<-time.After(5 * time.Millisecond)
+
+			fp := &bytes.Buffer{}
+			if err := tc.WriteSnapshot(fp, codec); err != nil {
+				t.Fatal("Couldn't write snapshot:", err)
+			}
+
+			oc := New(DefaultExpiration, 0)
+			oc.Set("a", &TestStruct{Num: 10}, DefaultExpiration) // this should not be overwritten
+			n, err := oc.ReadSnapshot(fp, codec)
+			if err != nil {
+				t.Fatal("Couldn't read snapshot:", err)
+			}
+			if n != 1 {
+				t.Errorf("%d items were added, not 1", n)
+			}
+			if a, _ := oc.Get("a"); a.(*TestStruct).Num != 10 {
+				t.Error("a was overwritten")
+			}
+			b, expiration, found := oc.GetWithExpiration("b")
+			if !found {
+				t.Fatal("b was not found")
+			}
+			if bb := b.(*TestStruct); bb.Num != 2 || len(bb.Children) != 1 || bb.Children[0].Num != 3 {
+				t.Error("b is not restored:", bb)
+			}
+			if _, want, _ := tc.GetWithExpiration("b"); !expiration.Equal(want) {
+				t.Error("expiration for b is not restored:", expiration)
+			}
+			if _, found := oc.Get("expired"); found {
+				t.Error("expired was found")
+			}
+		})
+	}
+}
+
+func Test_SnapshotCustomCodec(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", "abc", DefaultExpiration)
+	fp := &bytes.Buffer{}
+	if err := tc.WriteSnapshot(fp, upperCodec{}); err != nil {
+		t.Fatal("Couldn't write snapshot:", err)
+	}
+	oc := New(DefaultExpiration, 0)
+	if _, err := oc.ReadSnapshot(fp, upperCodec{}); err != nil {
+		t.Fatal("Couldn't read snapshot:", err)
+	}
+	if a, _ := oc.Get("a"); a.(string) != "ABC" {
+		t.Error("a is not ABC:", a)
+	}
+}
+
+func Test_SnapshotErrors(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", 1, DefaultExpiration)
+
+	if err := tc.WriteSnapshot(&bytes.Buffer{}, JSONCodec[string]{}); err == nil {
+		t.Error("WriteSnapshot did not fail on a value of the wrong type")
+	}
+
+	fp := &bytes.Buffer{}
+	if err := tc.WriteSnapshot(fp, JSONCodec[int]{}); err != nil {
+		t.Fatal("Couldn't write snapshot:", err)
+	}
+	data := fp.Bytes()
+
+	oc := New(DefaultExpiration, 0)
+	if _, err := oc.ReadSnapshot(bytes.NewReader(data), GobCodec[int]{}); !errors.Is(err, ErrSnapshotCodec) {
+		t.Error("ReadSnapshot did not return ErrSnapshotCodec:", err)
+	}
+	if _, err := oc.ReadSnapshot(bytes.NewReader([]byte("nope")), JSONCodec[int]{}); !errors.Is(err, ErrSnapshotFormat) {
+		t.Error("ReadSnapshot did not return ErrSnapshotFormat:", err)
+	}
+	if _, err := oc.ReadSnapshot(bytes.NewReader(data[:len(data)-3]), JSONCodec[int]{}); !errors.Is(err, ErrSnapshotFormat) {
+		t.Error("ReadSnapshot did not return ErrSnapshotFormat on a truncated snapshot:", err)
+	}
+	bad := bytes.Clone(data)
+	bad[len(snapshotMagic)] = SnapshotVersion + 1
+	if _, err := oc.ReadSnapshot(bytes.NewReader(bad), JSONCodec[int]{}); !errors.Is(err, ErrSnapshotVersion) {
+		t.Error("ReadSnapshot did not return ErrSnapshotVersion:", err)
+	}
+}
+
+func Test_SnapshotFile(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	tc := NewSharded(4, DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+	tc.Set("b", "b", DefaultExpiration)
+	if err := tc.SaveSnapshot(fname, JSONCodec[string]{}); err != nil {
+		t.Fatal("Couldn't save snapshot:", err)
+	}
+	// a failed save keeps the previous snapshot.
+	tc.Set("c", 3, DefaultExpiration)
+	if err := tc.SaveSnapshot(fname, JSONCodec[string]{}); err == nil {
+		t.Error("SaveSnapshot did not fail on a value of the wrong type")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(fname))
+	if len(entries) != 1 {
+		t.Error("temporary snapshot files were left behind:", entries)
+	}
+
+	oc := New(DefaultExpiration, 0)
+	n, err := oc.LoadSnapshot(fname, JSONCodec[string]{})
+	if err != nil {
+		t.Fatal("Couldn't load snapshot:", err)
+	}
+	if n != 2 {
+		t.Errorf("%d items were added, not 2", n)
+	}
+	if b, _ := oc.Get("b"); b.(string) != "b" {
+		t.Error("b is not b")
+	}
+}
+
+func Test_AutoSnapshot(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	tc := New(DefaultExpiration, 0)
+	tc.Set("a", "a", DefaultExpiration)
+	stop := tc.AutoSnapshot(fname, GobCodec[string]{}, 5*time.Millisecond, func(err error) {
+		t.Error("auto snapshot failed:", err)
+	})
+	<-time.After(20 * time.Millisecond)
+	if _, err := os.Stat(fname); err != nil {
+		t.Error("snapshot was not saved periodically:", err)
+	}
+	tc.Set("b", "b", DefaultExpiration)
+	if err := stop(); err != nil {
+		t.Fatal("Couldn't save the last snapshot:", err)
+	}
+	if err := stop(); err != nil {
+		t.Error("second stop failed:", err)
+	}
+
+	oc := NewSharded(2, DefaultExpiration, 0)
+	if n, err := oc.LoadSnapshot(fname, GobCodec[string]{}); err != nil || n != 2 {
+		t.Error("Couldn't load the last snapshot:", n, err)
+	}
+
+	fname = filepath.Join(t.TempDir(), "disabled.snapshot")
+	stop = tc.AutoSnapshot(fname, GobCodec[string]{}, 0, nil)
+	<-time.After(5 * time.Millisecond)
+	if _, err := os.Stat(fname); err == nil {
+		t.Error("snapshot was saved periodically with a zero interval")
+	}
+	if err := stop(); err != nil {
+		t.Fatal("Couldn't save the last snapshot:", err)
+	}
+	if _, err := os.Stat(fname); err != nil {
+		t.Error("the last snapshot was not saved:", err)
+	}
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the cache statistics.
+type Stats struct {
+	Hits          uint64        // reads which found the key
+	Misses        uint64        // reads which did not find the key
+	Sets          uint64        // items added to or overwritten in the cache
+	Expirations   uint64        // expired items removed from the cache
+	Evictions     uint64        // items evicted to honour the capacity limits
+	Rejections    uint64        // items not stored because of their cost or size
+	LoadSuccesses uint64        // loads and refreshes which succeeded
+	LoadFailures  uint64        // loads and refreshes which failed
+	TotalLoadTime time.Duration // time spent in loads and refreshes
+}
+
+// Requests returns the number of reads, hits plus misses.
+func (s Stats) Requests() uint64 { return s.Hits + s.Misses }
+
+// HitRatio returns the ratio of reads which found the key, or 0 if there were none.
+func (s Stats) HitRatio() float64 {
+	if n := s.Requests(); n > 0 {
+		return float64(s.Hits) / float64(n)
+	}
+	return 0
+}
+
+// add returns the sum of both snapshots.
+func (s Stats) add(o Stats) Stats {
+	return Stats{
+		Hits:          s.Hits + o.Hits,
+		Misses:        s.Misses + o.Misses,
+		Sets:          s.Sets + o.Sets,
+		Expirations:   s.Expirations + o.Expirations,
+		Evictions:     s.Evictions + o.Evictions,
+		Rejections:    s.Rejections + o.Rejections,
+		LoadSuccesses: s.LoadSuccesses + o.LoadSuccesses,
+		LoadFailures:  s.LoadFailures + o.LoadFailures,
+		TotalLoadTime: s.TotalLoadTime + o.TotalLoadTime,
+	}
+}
+
+// stats records the statistics with atomic counters, so it is safe to
+// update while holding only the read lock of the cache, or no lock at all.
+type stats struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	sets          atomic.Uint64
+	expirations   atomic.Uint64
+	evictions     atomic.Uint64
+	rejections    atomic.Uint64
+	loadSuccesses atomic.Uint64
+	loadFailures  atomic.Uint64
+	loadTime      atomic.Int64
+}
+
+func (s *stats) recordRead(found bool) {
+	if found {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+func (s *stats) recordEvicted(reason EvictReason) {
+	switch reason {
+	case EvictExpired:
+		s.expirations.Add(1)
+	case EvictCapacity:
+		s.evictions.Add(1)
+	case EvictDeleted, EvictCleared, EvictReplaced:
+	}
+}
+
+func (s *stats) recordLoad(d time.Duration, err error) {
+	if err == nil {
+		s.loadSuccesses.Add(1)
+	} else {
+		s.loadFailures.Add(1)
+	}
+	s.loadTime.Add(int64(d))
+}
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		Hits:          s.hits.Load(),
+		Misses:        s.misses.Load(),
+		Sets:          s.sets.Load(),
+		Expirations:   s.expirations.Load(),
+		Evictions:     s.evictions.Load(),
+		Rejections:    s.rejections.Load(),
+		LoadSuccesses: s.loadSuccesses.Load(),
+		LoadFailures:  s.loadFailures.Load(),
+		TotalLoadTime: time.Duration(s.loadTime.Load()),
+	}
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *cache) Stats() Stats {
+	return c.stats.snapshot()
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Stats(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2))
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, time.Millisecond)
+	tc.Get("a")
+	tc.Get("missing")
+	tc.GetEx("a", DefaultExpiration)
+	tc.GetWithExpiration("missing")
+	tc.Set("c", 3, DefaultExpiration) // evicts b
+	tc.Set("d", 4, time.Millisecond)  // evicts a
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired() // expires d
+	tc.GetDel("c")
+
+	want := Stats{
+		Hits:        3,
+		Misses:      2,
+		Sets:        4,
+		Expirations: 1,
+		Evictions:   2,
+	}
+	if got := tc.Stats(); got != want {
+		t.Errorf("Stats is not %+v: %+v", want, got)
+	}
+	if r := tc.Stats().HitRatio(); r != 0.6 {
+		t.Error("HitRatio is not 0.6:", r)
+	}
+	if r := (Stats{}).HitRatio(); r != 0 {
+		t.Error("HitRatio of empty stats is not 0:", r)
+	}
+}
+
+func Test_StatsLoad(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	_, _ = tc.GetOrLoad(context.Background(), "a", func(context.Context) (any, time.Duration, error) {
+		<-time.After(time.Millisecond)
+		return 1, DefaultExpiration, nil
+	})
+	_, _ = tc.GetOrLoad(context.Background(), "b", func(context.Context) (any, time.Duration, error) {
+		return nil, DefaultExpiration, errors.New("load failed")
+	})
+	_, _ = tc.GetOrLoad(context.Background(), "a", func(context.Context) (any, time.Duration, error) {
+		return 1, DefaultExpiration, nil
+	})
+
+	s := tc.Stats()
+	if s.Hits != 1 || s.Misses != 2 {
+		t.Error("Hits and Misses are not 1 and 2:", s.Hits, s.Misses)
+	}
+	if s.LoadSuccesses != 1 || s.LoadFailures != 1 {
+		t.Error("LoadSuccesses and LoadFailures are not 1 and 1:", s.LoadSuccesses, s.LoadFailures)
+	}
+	if s.TotalLoadTime < time.Millisecond {
+		t.Error("TotalLoadTime is less than 1ms:", s.TotalLoadTime)
+	}
+}
+
+func Test_StatsConcurrent(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	tc.Set("foo", "bar", DefaultExpiration)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				tc.Get("foo")
+				tc.Get("baz")
+			}
+		}()
+	}
+	wg.Wait()
+	if s := tc.Stats(); s.Hits != 800 || s.Misses != 800 || s.Sets != 1 {
+		t.Errorf("Stats is not 800 hits, 800 misses and 1 set: %+v", s)
+	}
+}
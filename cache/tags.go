@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"slices"
+	"time"
+)
+
+// SetWithTags set an item to the cache with tags, replacing any existing item,
+// see Set. InvalidateTag removes all the items carrying a tag, e.g. all the
+// pages derived from "tenant:42". The tags of an item are replaced by Set,
+// and kept by the updates of its value, such as Upsert, CompareAndSwap and Incr.
+func (c *cache) SetWithTags(k string, x any, d time.Duration, tags ...string) {
+	e := c.calcExpiration(d)
+	c.mu.Lock()
+	c.set(k, Item{
+		Value:      x,
+		Expiration: e,
+		tags:       slices.Clone(tags),
+	})
+	c.unlock()
+}
+
+// InvalidateTag deletes all items carrying the tag, calling OnEvicted for each,
+// with EvictExpired for the items which have expired, as DeletePrefix does.
+// Returns the number of unexpired items deleted.
+func (c *cache) InvalidateTag(tag string) int {
+	n := 0
+	c.mu.Lock()
+	for k := range c.tags[tag] {
+		if item := c.items[k]; item.Expired() {
+			c.remove(k, EvictExpired)
+		} else {
+			c.remove(k, EvictDeleted)
+			n++
+		}
+	}
+	c.unlock()
+	return n
+}
+
+// tagItem adds the key to the index of its tags.
+// It must be called with c.mu held.
+func (c *cache) tagItem(k string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			if c.tags == nil {
+				c.tags = make(map[string]map[string]struct{})
+			}
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[k] = struct{}{}
+	}
+}
+
+// untagItem removes the key from the index of its tags.
+// It must be called with c.mu held.
+func (c *cache) untagItem(k string, tags []string) {
+	for _, tag := range tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, k)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_InvalidateTag(t *testing.T) {
+	var evicted []string
+	tc := NewSharded(4, DefaultExpiration, 0).OnEvictedWithReason(func(k string, _ any, reason EvictReason) {
+		if reason == EvictDeleted {
+			evicted = append(evicted, k)
+		}
+	})
+	tc.SetWithTags("page:1", 1, DefaultExpiration, "tenant:42", "page")
+	tc.SetWithTags("page:2", 2, DefaultExpiration, "tenant:42", "page")
+	tc.SetWithTags("page:3", 3, DefaultExpiration, "tenant:7", "page")
+	tc.Set("other", 4, DefaultExpiration)
+
+	if n := tc.InvalidateTag("tenant:42"); n != 2 {
+		t.Error("InvalidateTag did not delete 2 items:", n)
+	}
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []string{"page:1", "page:2"}) {
+		t.Error("OnEvicted was not called for the invalidated items:", evicted)
+	}
+	if n := tc.Count(); n != 2 {
+		t.Error("InvalidateTag deleted other items:", n)
+	}
+	if n := tc.InvalidateTag("tenant:42"); n != 0 {
+		t.Error("InvalidateTag deleted items twice:", n)
+	}
+	if n := tc.InvalidateTag("page"); n != 1 {
+		t.Error("InvalidateTag did not delete page:3:", n)
+	}
+
+	tc.SetWithTags("page:4", 4, time.Millisecond, "page")
+	<-time.After(5 * time.Millisecond)
+	evicted = nil
+	if n := tc.InvalidateTag("page"); n != 0 {
+		t.Error("InvalidateTag counted an expired item:", n)
+	}
+	if len(evicted) != 0 {
+		t.Error("the expired item was reported as deleted:", evicted)
+	}
+	if n := tc.Stats().Expirations; n != 1 {
+		t.Error("the expired item was not counted as an expiration:", n)
+	}
+}
+
+func Test_TagIndex(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	tc.SetWithTags("a", 1, time.Millisecond, "t")
+	tc.SetWithTags("b", 2, DefaultExpiration, "t")
+	tc.SetWithTags("c", 3, DefaultExpiration, "t")
+	tc.SetWithTags("d", 4, DefaultExpiration, "t")
+	tc.Set("b", 2, DefaultExpiration) // replaces the tags
+	tc.Upsert("c", func(bool, any) any { return 30 }, DefaultExpiration)
+	tc.CompareAndSwap("d", 4, 40)
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+
+	if got := len(tc.tags["t"]); got != 2 {
+		t.Error("tag index is not consistent with the items:", tc.tags)
+	}
+	if n := tc.InvalidateTag("t"); n != 2 {
+		t.Error("InvalidateTag did not delete c and d:", n)
+	}
+	if _, found := tc.Get("b"); !found {
+		t.Error("b was invalidated, although its tags were replaced")
+	}
+	if len(tc.tags) != 0 {
+		t.Error("tag index is not empty:", tc.tags)
+	}
+
+	tc.SetWithTags("e", 5, DefaultExpiration, "t")
+	tc.Clear()
+	if len(tc.tags) != 0 {
+		t.Error("Clear did not reset the tag index:", tc.tags)
+	}
+
+	tc.SetWithTags("f", 6, DefaultExpiration, "t")
+	oc := NewFrom(DefaultExpiration, 0, tc.Items())
+	if n := oc.InvalidateTag("t"); n != 1 {
+		t.Error("NewFrom did not index the tags of the items:", n)
+	}
+}
@@ -0,0 +1,407 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTieredClosed is returned by the write methods of a closed Tiered cache.
+var ErrTieredClosed = errors.New("cache: tiered cache closed")
+
+// RemoteStore is the remote tier of a Tiered cache, e.g. a Redis or Memcached
+// client, shared by the processes. The values are encoded by the Codec of the
+// Tiered cache. Implementations must be safe for concurrent use.
+type RemoteStore interface {
+	// Get returns the value of the key, its remaining time to live, and a
+	// bool indicating whether the key was found. A ttl <= 0 means the value
+	// never expires.
+	Get(ctx context.Context, key string) (value []byte, ttl time.Duration, found bool, err error)
+	// Set sets the value of the key, with the time to live. A ttl <= 0 means
+	// the value never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete deletes the key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Invalidation is the message of an InvalidationBus, telling the peers to drop
+// the key from their local tier.
+type Invalidation struct {
+	Source string // id of the Tiered cache which changed the key
+	Key    string
+}
+
+// InvalidationBus broadcasts invalidations between the Tiered caches of the
+// processes, e.g. over Redis pub/sub or NATS. Implementations must be safe
+// for concurrent use.
+type InvalidationBus interface {
+	// Publish sends the invalidation to all subscribers, including the
+	// publisher's own subscription.
+	Publish(ctx context.Context, msg Invalidation) error
+	// Subscribe calls fn for every invalidation published, until cancel is called.
+	Subscribe(fn func(Invalidation)) (cancel func(), err error)
+}
+
+// TieredOption configures a Tiered cache.
+type TieredOption func(*Tiered)
+
+// WithWriteBehind writes the changes to the remote tier asynchronously, in
+// order, through a queue of the given size, instead of writing through.
+// Set and Delete block while the queue is full. Errors of the remote writes
+// are reported to the handler of WithErrorHandler.
+func WithWriteBehind(queueSize int) TieredOption {
+	return func(t *Tiered) {
+		t.writeBehind = true
+		t.queueSize = max(queueSize, 1)
+	}
+}
+
+// WithInvalidationBus broadcasts the changes to the peers through the bus,
+// and drops the keys changed by the peers from the local tier.
+func WithInvalidationBus(bus InvalidationBus) TieredOption {
+	return func(t *Tiered) {
+		t.bus = bus
+	}
+}
+
+// WithLocalTTL caps the expiration of the items in the local tier, bounding
+// how long a process may serve a value changed by a peer if an invalidation
+// is lost. Items filled from the remote tier expire after it too, or with
+// their remote time to live if it is shorter.
+func WithLocalTTL(d time.Duration) TieredOption {
+	return func(t *Tiered) {
+		t.localTTL = d
+	}
+}
+
+// WithErrorHandler sets a function called with the errors which can not be
+// returned to the caller, such as the errors of write behind.
+func WithErrorHandler(f func(error)) TieredOption {
+	return func(t *Tiered) {
+		t.onError = f
+	}
+}
+
+// Tiered is a two-level cache: an in-process Cache in front of a RemoteStore.
+// Reads are served by the local tier, and filled from the remote tier on
+// miss, with concurrent misses of a key deduplicated. Writes go to both
+// tiers, and are broadcast to the peers through an optional InvalidationBus.
+type Tiered struct {
+	id          string
+	local       *Cache
+	remote      RemoteStore
+	codec       Codec
+	bus         InvalidationBus
+	localTTL    time.Duration
+	onError     func(error)
+	writeBehind bool
+	queueSize   int
+
+	unsubscribe func()
+	mu          sync.RWMutex // guards closed, and the sends to queue
+	closed      bool
+	queue       chan remoteWrite
+	done        chan struct{}
+
+	fillMu sync.Mutex
+	fills  map[string]*fillState // the keys being filled from the remote tier
+}
+
+// fillState tracks the fills of a key from the remote tier. gen is bumped by
+// every change of the key, so that a fill started before the change does not
+// store the value it read.
+type fillState struct {
+	gen   uint64
+	count int
+}
+
+// remoteWrite is a write to the remote tier, a set or a delete.
+type remoteWrite struct {
+	key    string
+	value  []byte
+	ttl    time.Duration
+	delete bool
+}
+
+// NewTiered returns a Tiered cache of the local and remote tiers, with the
+// values of the remote tier encoded by codec. The Tiered cache owns the local
+// cache, and closes it on Close.
+func NewTiered(local *Cache, remote RemoteStore, codec Codec, opts ...TieredOption) (*Tiered, error) {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	t := &Tiered{
+		id:     hex.EncodeToString(id[:]),
+		local:  local,
+		remote: remote,
+		codec:  codec,
+	}
+	for _, f := range opts {
+		f(t)
+	}
+	if t.bus != nil {
+		cancel, err := t.bus.Subscribe(t.invalidate)
+		if err != nil {
+			return nil, err
+		}
+		t.unsubscribe = cancel
+	}
+	if t.writeBehind {
+		t.queue = make(chan remoteWrite, t.queueSize)
+		t.done = make(chan struct{})
+		go t.runWriteBehind()
+	}
+	return t, nil
+}
+
+// Local returns the local tier.
+func (t *Tiered) Local() *Cache { return t.local }
+
+// Get returns the value of the key from the local tier, or fills it from the
+// remote tier on miss. Returns ErrValueNotFound if the key is in neither tier.
+// The filled value is dropped if the key is changed or invalidated while it is
+// read.
+func (t *Tiered) Get(ctx context.Context, k string) (any, error) {
+	var gen uint64
+	loader := func(ctx context.Context) (any, time.Duration, error) {
+		gen = t.beginFill(k)
+		filled := false
+		defer func() {
+			if !filled {
+				t.endFill(k)
+			}
+		}()
+		x, d, err := t.load(ctx, k)
+		filled = err == nil
+		return x, d, err
+	}
+	return t.local.getOrLoad(ctx, k, loader, func(k string, x any, d time.Duration) {
+		t.fill(k, gen, x, d)
+	})
+}
+
+// load reads the value of the key from the remote tier, with its local
+// expiration.
+func (t *Tiered) load(ctx context.Context, k string) (any, time.Duration, error) {
+	data, ttl, found, err := t.remote.Get(ctx, k)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !found {
+		return nil, 0, ErrValueNotFound
+	}
+	x, err := t.codec.Unmarshal(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ttl <= 0 {
+		// never expires remotely, the local tier decides.
+		ttl = DefaultExpiration
+	}
+	return x, t.localExpiration(ttl), nil
+}
+
+// beginFill registers a fill of the key, returning the generation it must be
+// stored with.
+func (t *Tiered) beginFill(k string) uint64 {
+	t.fillMu.Lock()
+	defer t.fillMu.Unlock()
+	if t.fills == nil {
+		t.fills = make(map[string]*fillState)
+	}
+	f, ok := t.fills[k]
+	if !ok {
+		f = &fillState{}
+		t.fills[k] = f
+	}
+	f.count++
+	return f.gen
+}
+
+// endFill unregisters a fill of the key.
+func (t *Tiered) endFill(k string) {
+	t.fillMu.Lock()
+	t.endFillLocked(k)
+	t.fillMu.Unlock()
+}
+
+// endFillLocked unregisters a fill of the key. It must be called with fillMu
+// held.
+func (t *Tiered) endFillLocked(k string) {
+	f := t.fills[k]
+	if f.count--; f.count == 0 {
+		delete(t.fills, k)
+	}
+}
+
+// fill stores the value of a fill of the key in the local tier, unless the key
+// was changed since the fill began, and unregisters the fill. The generation
+// is checked with the local tier locked, so that a change, which bumps the
+// generation before it updates the local tier, is never overwritten.
+func (t *Tiered) fill(k string, gen uint64, x any, d time.Duration) {
+	c := t.local.cache
+	e := c.calcExpiration(d)
+	c.mu.Lock()
+	t.fillMu.Lock()
+	current := t.fills[k].gen == gen
+	t.endFillLocked(k)
+	t.fillMu.Unlock()
+	if current {
+		c.set(k, Item{
+			Value:      x,
+			Expiration: e,
+		})
+	}
+	c.unlock()
+}
+
+// changed bumps the generation of the key, so that the fills in flight do not
+// store a stale value, and drops its negatively cached error. It must be
+// called before the local tier is updated.
+func (t *Tiered) changed(k string) {
+	t.local.loads.forget(k)
+	t.fillMu.Lock()
+	if f, ok := t.fills[k]; ok {
+		f.gen++
+	}
+	t.fillMu.Unlock()
+}
+
+// Set sets the value of the key in both tiers, with the expiration d, see
+// Cache.Set. With write through, the remote tier is written first, and the
+// local tier is only updated if it succeeded. With write behind, the peers
+// are invalidated once the remote tier is written.
+func (t *Tiered) Set(ctx context.Context, k string, x any, d time.Duration) error {
+	data, err := t.codec.Marshal(x)
+	if err != nil {
+		return err
+	}
+	ttl := d
+	if ttl == DefaultExpiration {
+		ttl = t.local.defaultExpiration
+	}
+	if err = t.write(ctx, remoteWrite{key: k, value: data, ttl: ttl}); err != nil {
+		return err
+	}
+	t.changed(k)
+	t.local.Set(k, x, t.localExpiration(d))
+	if t.writeBehind {
+		return nil // published by runWriteBehind
+	}
+	return t.publish(ctx, k)
+}
+
+// Delete deletes the key from both tiers, see Set.
+func (t *Tiered) Delete(ctx context.Context, k string) error {
+	if err := t.write(ctx, remoteWrite{key: k, delete: true}); err != nil {
+		return err
+	}
+	t.changed(k)
+	t.local.Delete(k)
+	if t.writeBehind {
+		return nil // published by runWriteBehind
+	}
+	return t.publish(ctx, k)
+}
+
+// Close flushes the writes behind, stops listening to the invalidations, and
+// closes the local tier. It does not close the remote store or the bus.
+func (t *Tiered) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	if t.queue != nil {
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	if t.done != nil {
+		<-t.done
+	}
+	if t.unsubscribe != nil {
+		t.unsubscribe()
+	}
+	return t.local.Close()
+}
+
+// write writes through to the remote tier, or queues the write behind.
+func (t *Tiered) write(ctx context.Context, w remoteWrite) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return ErrTieredClosed
+	}
+	if t.writeBehind {
+		select {
+		case t.queue <- w:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return t.writeRemote(ctx, w)
+}
+
+func (t *Tiered) writeRemote(ctx context.Context, w remoteWrite) error {
+	if w.delete {
+		return t.remote.Delete(ctx, w.key)
+	}
+	return t.remote.Set(ctx, w.key, w.value, w.ttl)
+}
+
+func (t *Tiered) runWriteBehind() {
+	defer close(t.done)
+	for w := range t.queue {
+		// published only once written, so that a peer can not refill the
+		// old value from the remote tier.
+		err := t.writeRemote(context.Background(), w)
+		if err == nil {
+			err = t.publish(context.Background(), w.key)
+		}
+		if err != nil {
+			t.reportError(err)
+		}
+	}
+}
+
+// publish tells the peers to drop the key from their local tier.
+func (t *Tiered) publish(ctx context.Context, k string) error {
+	if t.bus == nil {
+		return nil
+	}
+	return t.bus.Publish(ctx, Invalidation{Source: t.id, Key: k})
+}
+
+// invalidate drops the key changed by a peer from the local tier.
+func (t *Tiered) invalidate(msg Invalidation) {
+	if msg.Source != t.id {
+		t.changed(msg.Key)
+		t.local.Delete(msg.Key)
+	}
+}
+
+// localExpiration caps the expiration d by the local ttl.
+func (t *Tiered) localExpiration(d time.Duration) time.Duration {
+	if t.localTTL <= 0 {
+		return d
+	}
+	if d == DefaultExpiration {
+		d = t.local.defaultExpiration
+	}
+	if d <= 0 || d > t.localTTL {
+		return t.localTTL
+	}
+	return d
+}
+
+func (t *Tiered) reportError(err error) {
+	if t.onError != nil {
+		t.onError(err)
+	}
+}
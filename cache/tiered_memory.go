@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory RemoteStore, to test Tiered caches offline,
+// or to share a remote tier between the Tiered caches of one process.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string]memoryValue
+}
+
+type memoryValue struct {
+	data       []byte
+	expiration int64
+}
+
+var _ RemoteStore = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]memoryValue),
+	}
+}
+
+// Get implements RemoteStore.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, time.Duration, bool, error) {
+	s.mu.RLock()
+	v, found := s.items[key]
+	s.mu.RUnlock()
+	if !found {
+		return nil, 0, false, nil
+	}
+	var ttl time.Duration
+	if v.expiration > 0 {
+		ttl = time.Duration(v.expiration - time.Now().UnixNano())
+		if ttl <= 0 {
+			return nil, 0, false, nil
+		}
+	}
+	return bytes.Clone(v.data), ttl, true, nil
+}
+
+// Set implements RemoteStore.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	var e int64
+	if ttl > 0 {
+		e = time.Now().Add(ttl).UnixNano()
+	}
+	s.mu.Lock()
+	s.items[key] = memoryValue{data: bytes.Clone(value), expiration: e}
+	s.mu.Unlock()
+	return nil
+}
+
+// Delete implements RemoteStore.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.items, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// MemoryBus is an in-memory InvalidationBus, delivering the invalidations
+// synchronously to the subscribers of the process.
+type MemoryBus struct {
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[uint64]func(Invalidation)
+}
+
+var _ InvalidationBus = (*MemoryBus)(nil)
+
+// NewMemoryBus returns a MemoryBus without subscribers.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subs: make(map[uint64]func(Invalidation)),
+	}
+}
+
+// Publish implements InvalidationBus.
+func (b *MemoryBus) Publish(_ context.Context, msg Invalidation) error {
+	b.mu.RLock()
+	subs := make([]func(Invalidation), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.RUnlock()
+	for _, fn := range subs {
+		fn(msg)
+	}
+	return nil
+}
+
+// Subscribe implements InvalidationBus.
+func (b *MemoryBus) Subscribe(fn func(Invalidation)) (func(), error) {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = fn
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, id)
+		b.mu.Unlock()
+	}, nil
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type failingStore struct {
+	*MemoryStore
+	err error
+}
+
+func (s failingStore) Set(context.Context, string, []byte, time.Duration) error { return s.err }
+
+// blockingStore signals reading on every Get, then blocks it until release is
+// closed.
+type blockingStore struct {
+	*MemoryStore
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (s blockingStore) Get(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	data, ttl, found, err := s.MemoryStore.Get(ctx, key)
+	s.reading <- struct{}{}
+	<-s.release
+	return data, ttl, found, err
+}
+
+// gatedStore blocks every Set until release is closed.
+type gatedStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s gatedStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	<-s.release
+	return s.MemoryStore.Set(ctx, key, value, ttl)
+}
+
+func newTestTiered(t *testing.T, remote RemoteStore, opts ...TieredOption) *Tiered {
+	t.Helper()
+	tc, err := NewTiered(New(DefaultExpiration, 0), remote, JSONCodec[string]{}, opts...)
+	if err != nil {
+		t.Fatal("NewTiered failed:", err)
+	}
+	t.Cleanup(func() { _ = tc.Close() })
+	return tc
+}
+
+func Test_TieredFillOnMiss(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryStore()
+	_ = remote.Set(ctx, "a", []byte(`"remote"`), 0)
+
+	tc := newTestTiered(t, remote, WithLocalTTL(time.Minute))
+	v, err := tc.Get(ctx, "a")
+	if err != nil || v.(string) != "remote" {
+		t.Fatal("a was not filled from the remote tier:", v, err)
+	}
+	if d, found := tc.Local().TTL("a"); !found || d <= 0 || d > time.Minute {
+		t.Error("a was not filled with the local ttl:", d, found)
+	}
+	_ = remote.Delete(ctx, "a")
+	if v, err := tc.Get(ctx, "a"); err != nil || v.(string) != "remote" {
+		t.Error("a was not served by the local tier:", v, err)
+	}
+	if _, err := tc.Get(ctx, "missing"); !errors.Is(err, ErrValueNotFound) {
+		t.Error("Get did not return ErrValueNotFound:", err)
+	}
+
+	_ = remote.Set(ctx, "b", []byte(`"remote"`), time.Second)
+	tc = newTestTiered(t, remote)
+	if _, err := tc.Get(ctx, "b"); err != nil {
+		t.Fatal("b was not filled from the remote tier:", err)
+	}
+	if d, found := tc.Local().TTL("b"); !found || d <= 0 || d > time.Second {
+		t.Error("b was not filled with the remote ttl:", d, found)
+	}
+}
+
+func Test_TieredStaleFill(t *testing.T) {
+	ctx := context.Background()
+	remote := blockingStore{NewMemoryStore(), make(chan struct{}), make(chan struct{})}
+	_ = remote.Set(ctx, "a", []byte(`"old"`), 0)
+	bus := NewMemoryBus()
+	tc := newTestTiered(t, remote, WithInvalidationBus(bus))
+
+	fill := func() <-chan error {
+		errc := make(chan error, 1)
+		go func() {
+			_, err := tc.Get(ctx, "a")
+			errc <- err
+		}()
+		<-remote.reading
+		return errc
+	}
+
+	errc := fill()
+	if err := tc.Delete(ctx, "a"); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	remote.release <- struct{}{}
+	<-errc
+	if v, found := tc.Local().Get("a"); found {
+		t.Error("the fill in flight stored a deleted value:", v)
+	}
+
+	_ = remote.Set(ctx, "a", []byte(`"old"`), 0)
+	errc = fill()
+	_ = remote.Set(ctx, "a", []byte(`"new"`), 0)
+	_ = bus.Publish(ctx, Invalidation{Source: "peer", Key: "a"})
+	remote.release <- struct{}{}
+	<-errc
+	if v, found := tc.Local().Get("a"); found {
+		t.Error("the fill in flight stored an invalidated value:", v)
+	}
+
+	errc = fill()
+	remote.release <- struct{}{}
+	<-errc
+	if v, _ := tc.Local().Get("a"); v != "new" {
+		t.Error("a was not filled:", v)
+	}
+	if n := len(tc.fills); n != 0 {
+		t.Error("the fills were not unregistered:", n)
+	}
+}
+
+func Test_TieredWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryStore()
+	tc := newTestTiered(t, remote)
+
+	if err := tc.Set(ctx, "a", "a", DefaultExpiration); err != nil {
+		t.Fatal("Set failed:", err)
+	}
+	if data, _, found, _ := remote.Get(ctx, "a"); !found || string(data) != `"a"` {
+		t.Error("a was not written through:", string(data))
+	}
+	if err := tc.Set(ctx, "b", 1, DefaultExpiration); err == nil {
+		t.Error("Set did not fail on a value the codec can not encode")
+	}
+	if err := tc.Delete(ctx, "a"); err != nil {
+		t.Fatal("Delete failed:", err)
+	}
+	if _, _, found, _ := remote.Get(ctx, "a"); found {
+		t.Error("a was not deleted from the remote tier")
+	}
+	if _, found := tc.Local().Get("a"); found {
+		t.Error("a was not deleted from the local tier")
+	}
+
+	errRemote := errors.New("remote failed")
+	tc = newTestTiered(t, failingStore{NewMemoryStore(), errRemote})
+	if err := tc.Set(ctx, "a", "a", DefaultExpiration); !errors.Is(err, errRemote) {
+		t.Error("Set did not return the remote error:", err)
+	}
+	if _, found := tc.Local().Get("a"); found {
+		t.Error("a was set locally, although the remote write failed")
+	}
+}
+
+func Test_TieredWriteBehind(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryStore()
+	errRemote := errors.New("remote failed")
+
+	var mu sync.Mutex
+	var errs []error
+	tc, err := NewTiered(New(DefaultExpiration, 0), remote, JSONCodec[string]{}, WithWriteBehind(4))
+	if err != nil {
+		t.Fatal("NewTiered failed:", err)
+	}
+	for range 3 {
+		_ = tc.Set(ctx, "a", "a", DefaultExpiration)
+		_ = tc.Set(ctx, "b", "b", time.Minute)
+		_ = tc.Delete(ctx, "b")
+	}
+	if v, _ := tc.Local().Get("a"); v.(string) != "a" {
+		t.Error("a was not set locally:", v)
+	}
+	if err := tc.Close(); err != nil {
+		t.Fatal("Close failed:", err)
+	}
+	if _, _, found, _ := remote.Get(ctx, "a"); !found {
+		t.Error("a was not written behind before Close returned")
+	}
+	if _, _, found, _ := remote.Get(ctx, "b"); found {
+		t.Error("b was not deleted in order")
+	}
+	if err := tc.Set(ctx, "c", "c", DefaultExpiration); !errors.Is(err, ErrTieredClosed) {
+		t.Error("Set did not return ErrTieredClosed:", err)
+	}
+
+	tc, _ = NewTiered(New(DefaultExpiration, 0), failingStore{remote, errRemote}, JSONCodec[string]{},
+		WithWriteBehind(1),
+		WithErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	_ = tc.Set(ctx, "a", "a", DefaultExpiration)
+	_ = tc.Close()
+	if len(errs) != 1 || !errors.Is(errs[0], errRemote) {
+		t.Error("the write behind error was not reported:", errs)
+	}
+}
+
+func Test_TieredInvalidation(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryStore()
+	bus := NewMemoryBus()
+	tc1 := newTestTiered(t, remote, WithInvalidationBus(bus))
+	tc2 := newTestTiered(t, remote, WithInvalidationBus(bus))
+
+	_ = tc1.Set(ctx, "a", "v1", DefaultExpiration)
+	if v, _ := tc2.Get(ctx, "a"); v.(string) != "v1" {
+		t.Fatal("a is not v1 on the peer:", v)
+	}
+	_ = tc1.Set(ctx, "a", "v2", DefaultExpiration)
+	if _, found := tc1.Local().Get("a"); !found {
+		t.Error("a was invalidated by its own change")
+	}
+	if v, _ := tc2.Get(ctx, "a"); v.(string) != "v2" {
+		t.Error("a was not invalidated on the peer:", v)
+	}
+	_ = tc2.Delete(ctx, "a")
+	if _, found := tc1.Local().Get("a"); found {
+		t.Error("a was not invalidated by the peer delete")
+	}
+
+	_ = tc2.Close()
+	_ = tc1.Set(ctx, "b", "b", DefaultExpiration)
+	if n := len(bus.subs); n != 1 {
+		t.Error("Close did not unsubscribe from the bus:", n)
+	}
+}
+
+func Test_TieredWriteBehindInvalidation(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryStore()
+	_ = remote.Set(ctx, "a", []byte(`"v1"`), 0)
+	bus := NewMemoryBus()
+	gated := gatedStore{remote, make(chan struct{})}
+	tc1 := newTestTiered(t, gated, WithInvalidationBus(bus), WithWriteBehind(4))
+	tc2, err := NewTiered(New(DefaultExpiration, 0, WithNegativeCache(time.Minute)), remote, JSONCodec[string]{},
+		WithInvalidationBus(bus))
+	if err != nil {
+		t.Fatal("NewTiered failed:", err)
+	}
+	t.Cleanup(func() { _ = tc2.Close() })
+
+	if v, _ := tc2.Get(ctx, "a"); v != "v1" {
+		t.Fatal("a is not v1 on the peer:", v)
+	}
+	if _, err := tc2.Get(ctx, "b"); !errors.Is(err, ErrValueNotFound) {
+		t.Fatal("Get did not return ErrValueNotFound:", err)
+	}
+	_ = tc1.Set(ctx, "a", "v2", DefaultExpiration)
+	_ = tc1.Set(ctx, "b", "b", DefaultExpiration)
+	if v, _ := tc2.Get(ctx, "a"); v != "v1" {
+		t.Error("the peer was invalidated before the write behind:", v)
+	}
+	close(gated.release)
+	_ = tc1.Close() // flushes the writes behind
+
+	if v, _ := tc2.Get(ctx, "a"); v != "v2" {
+		t.Error("a was not invalidated on the peer after the write behind:", v)
+	}
+	if v, err := tc2.Get(ctx, "b"); err != nil || v != "b" {
+		t.Error("the negatively cached error of b was not invalidated:", v, err)
+	}
+}
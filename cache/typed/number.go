@@ -0,0 +1,33 @@
+package typed
+
+import (
+	"github.com/thinkgos/proc/cache"
+)
+
+// Incr increment an item by n. Returns an error if the item was not found.
+// If there is no error, the incremented value is returned.
+func Incr[K comparable, V cache.Number](c *Cache[K, V], k K, n V) (V, error) {
+	return c.update(k, func(v V) V { return v + n })
+}
+
+// Decr decrement an item by n. Returns an error if the item was not found.
+// If there is no error, the decremented value is returned.
+func Decr[K comparable, V cache.Number](c *Cache[K, V], k K, n V) (V, error) {
+	return c.update(k, func(v V) V { return v - n })
+}
+
+func (c *cacheOf[K, V]) update(k K, f func(V) V) (V, error) {
+	var nv V
+
+	c.mu.Lock()
+	v, found := c.items[k]
+	if !found || v.Expired() {
+		c.mu.Unlock()
+		return nv, cache.ErrValueNotFound
+	}
+	nv = f(v.Value)
+	v.Value = nv
+	c.items[k] = v
+	c.mu.Unlock()
+	return nv, nil
+}
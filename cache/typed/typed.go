@@ -0,0 +1,416 @@
+// Package typed implements a generic, type-safe in-memory key:value cache
+// with the same semantics as package cache, but without type assertions on
+// the caller side.
+package typed
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/thinkgos/proc/cache"
+)
+
+const (
+	// For use with functions that take an expiration time.
+	NoExpiration = cache.NoExpiration
+	// For use with functions that take an expiration time. Equivalent to
+	// passing in the same expiration duration as was given to New() or
+	// NewFrom() when the cache was created (e.g. 5 minutes.)
+	DefaultExpiration = cache.DefaultExpiration
+)
+
+type Item[V any] struct {
+	Value      V     // cache value
+	Expiration int64 // unix nanosecond
+}
+
+// Returns true if the item has expired.
+func (i *Item[V]) Expired() bool {
+	return i.Expiration > 0 && time.Now().UnixNano() > i.Expiration
+}
+
+type Cache[K comparable, V any] struct {
+	*cacheOf[K, V]
+	// If this is confusing, see the comment at the bottom of NewFrom()
+}
+
+type cacheOf[K comparable, V any] struct {
+	defaultExpiration time.Duration
+	items             map[K]Item[V]
+	mu                sync.RWMutex
+	onEvicted         func(K, V)
+	janitor           *janitor
+}
+
+// Set an item to the cache, replacing any existing item.
+// If the duration is 0(DefaultExpiration), the cache's default expiration time is used.
+// If it is -1(NoExpiration), the item never expires.
+func (c *cacheOf[K, V]) Set(k K, x V, d time.Duration) {
+	e := c.calcExpiration(d)
+	c.mu.Lock()
+	c.items[k] = Item[V]{
+		Value:      x,
+		Expiration: e,
+	}
+	c.mu.Unlock()
+}
+
+// SetDefault set an item to the cache, replacing any existing item, using the default
+// expiration.
+func (c *cacheOf[K, V]) SetDefault(k K, x V) {
+	c.Set(k, x, DefaultExpiration)
+}
+
+// SetNX set an item to the cache only if an item doesn't already exist for the given
+// key, or if the existing item has expired. Returns an true if set success.
+func (c *cacheOf[K, V]) SetNX(k K, x V, d time.Duration) bool {
+	c.mu.Lock()
+	_, found := c.getValue(k)
+	if found {
+		c.mu.Unlock()
+		return false
+	}
+	c.items[k] = Item[V]{
+		Value:      x,
+		Expiration: c.calcExpiration(d),
+	}
+	c.mu.Unlock()
+	return true
+}
+
+// SetXX set a new value for the cache key only if it already exists, and the existing
+// item hasn't expired. Returns the old value and true if set success.
+func (c *cacheOf[K, V]) SetXX(k K, x V, d time.Duration) (V, bool) {
+	c.mu.Lock()
+	val, found := c.getValue(k)
+	if !found {
+		c.mu.Unlock()
+		return val, false
+	}
+	c.items[k] = Item[V]{
+		Value:      x,
+		Expiration: c.calcExpiration(d),
+	}
+	c.mu.Unlock()
+	return val, true
+}
+
+type UpsertCb[V any] func(exist bool, valueInMap V) V
+
+func (c *cacheOf[K, V]) Upsert(k K, cb UpsertCb[V], d time.Duration) (val V) {
+	var e int64
+	var zero V
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, found := c.items[k]
+	if !found || v.Expired() {
+		val = cb(false, zero)
+		e = c.calcExpiration(d)
+	} else {
+		val = cb(true, v.Value)
+		e = v.Expiration
+	}
+	c.items[k] = Item[V]{
+		Value:      val,
+		Expiration: e,
+	}
+	return val
+}
+
+func (c *cacheOf[K, V]) calcExpiration(d time.Duration) int64 {
+	if d == DefaultExpiration {
+		d = c.defaultExpiration
+	}
+	if d > 0 {
+		return time.Now().Add(d).UnixNano()
+	} else {
+		return 0
+	}
+}
+
+// Get an item from the cache. Returns the item or zero value, and a bool indicating
+// whether the key was found.
+func (c *cacheOf[K, V]) Get(k K) (V, bool) {
+	c.mu.RLock()
+	val, found := c.getValue(k)
+	c.mu.RUnlock()
+	return val, found
+}
+
+type InsertCb[V any] func() V
+
+func (c *cacheOf[K, V]) GetOrNew(k K, cb InsertCb[V], d time.Duration) V {
+	c.mu.RLock()
+	val, found := c.getValue(k)
+	c.mu.RUnlock()
+	if found {
+		return val
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// double check
+	val, found = c.getValue(k)
+	if found {
+		return val
+	}
+	val = cb()
+	c.items[k] = Item[V]{
+		Value:      val,
+		Expiration: c.calcExpiration(d),
+	}
+	return val
+}
+
+// GetEx get an item from the cache. Returns the item or zero value, and a bool indicating
+// whether the key was found. if key found, update with new expires.
+func (c *cacheOf[K, V]) GetEx(k K, d time.Duration) (V, bool) {
+	var zero V
+
+	c.mu.Lock()
+	item, found := c.items[k]
+	if !found || item.Expired() {
+		c.mu.Unlock()
+		return zero, false
+	}
+	item.Expiration = c.calcExpiration(d)
+	c.items[k] = item
+	c.mu.Unlock()
+	return item.Value, true
+}
+
+// GetDel get an item from the cache the delete it from the cache. Returns the item or zero value,
+// and a bool indicating whether the key was found. if key found, delete it.
+func (c *cacheOf[K, V]) GetDel(k K) (V, bool) {
+	var zero V
+
+	c.mu.Lock()
+	item, found := c.items[k]
+	if !found || item.Expired() {
+		c.mu.Unlock()
+		return zero, false
+	}
+	delete(c.items, k)
+	onEvicted := c.onEvicted
+	c.mu.Unlock()
+	if onEvicted != nil {
+		onEvicted(k, item.Value)
+	}
+	return item.Value, true
+}
+
+// GetWithExpiration returns an item and its expiration time from the cache.
+// It returns the item or zero value, the expiration time if one is set (if the item
+// never expires a zero value for time.Time is returned), and a bool indicating
+// whether the key was found.
+func (c *cacheOf[K, V]) GetWithExpiration(k K) (V, time.Time, bool) {
+	var zero V
+
+	c.mu.RLock()
+	item, found := c.items[k]
+	c.mu.RUnlock()
+	if !found {
+		return zero, time.Time{}, false
+	}
+	if item.Expiration > 0 {
+		if time.Now().UnixNano() > item.Expiration {
+			return zero, time.Time{}, false
+		}
+		return item.Value, time.Unix(0, item.Expiration), true
+	}
+	return item.Value, time.Time{}, true
+}
+
+func (c *cacheOf[K, V]) getValue(k K) (V, bool) {
+	var zero V
+
+	item, found := c.items[k]
+	if !found {
+		return zero, false
+	}
+	// "Inlining" of Expired
+	if item.Expired() {
+		return zero, false
+	}
+	return item.Value, true
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (c *cacheOf[K, V]) Delete(k K) {
+	var val Item[V]
+	var found bool
+
+	c.mu.Lock()
+	onEvicted := c.onEvicted
+	if onEvicted != nil {
+		val, found = c.items[k]
+	}
+	delete(c.items, k)
+	c.mu.Unlock()
+	if found {
+		onEvicted(k, val.Value)
+	}
+}
+
+type keyValPair[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// Delete all expired items from the cache.
+func (c *cacheOf[K, V]) DeleteExpired() {
+	var evictedItems []keyValPair[K, V]
+
+	now := time.Now().UnixNano()
+	c.mu.Lock()
+	onEvicted := c.onEvicted
+	for k, v := range c.items {
+		// "Inlining" of expired
+		if v.Expiration > 0 && now > v.Expiration {
+			delete(c.items, k)
+			if onEvicted != nil {
+				evictedItems = append(evictedItems, keyValPair[K, V]{k, v.Value})
+			}
+		}
+	}
+	c.mu.Unlock()
+	for _, v := range evictedItems {
+		onEvicted(v.key, v.value)
+	}
+}
+
+// Clear all items from the cache.
+func (c *cacheOf[K, V]) Clear() {
+	c.mu.Lock()
+	onEvicted := c.onEvicted
+	old := c.items
+	c.items = make(map[K]Item[V])
+	c.mu.Unlock()
+	if onEvicted != nil {
+		for k, v := range old {
+			onEvicted(k, v.Value)
+		}
+	}
+}
+
+// Expire  update with new expires if key found, and return a bool indicating
+// whether update expires successfully
+func (c *cacheOf[K, V]) Expire(k K, d time.Duration) bool {
+	c.mu.Lock()
+	item, found := c.items[k]
+	if !found || item.Expired() {
+		c.mu.Unlock()
+		return false
+	}
+	item.Expiration = c.calcExpiration(d)
+	c.items[k] = item
+	c.mu.Unlock()
+	return true
+}
+
+func (c *cacheOf[K, V]) setOnEvicted(f func(K, V)) {
+	c.mu.Lock()
+	c.onEvicted = f
+	c.mu.Unlock()
+}
+
+// Items Copies all unexpired items in the cache into a new map and returns it.
+func (c *cacheOf[K, V]) Items() map[K]Item[V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m := make(map[K]Item[V], len(c.items))
+	now := time.Now().UnixNano()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// Count returns the number of items in the cache. This may include items that have
+// expired, but have not yet been cleaned up.
+func (c *cacheOf[K, V]) Count() int {
+	c.mu.RLock()
+	n := len(c.items)
+	c.mu.RUnlock()
+	return n
+}
+
+type janitor struct {
+	interval time.Duration
+	stop     chan struct{}
+}
+
+func (j *janitor) Run(c interface{ DeleteExpired() }) {
+	ticker := time.NewTicker(j.interval)
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-j.stop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+func stopJanitor[K comparable, V any](c *Cache[K, V]) {
+	c.janitor.stop <- struct{}{}
+}
+
+func runJanitor[K comparable, V any](c *cacheOf[K, V], ci time.Duration) {
+	j := &janitor{
+		interval: ci,
+		stop:     make(chan struct{}),
+	}
+	c.janitor = j
+	go c.janitor.Run(c)
+}
+
+// New return a new cache with a given default expiration duration and cleanup
+// interval. If the expiration duration is less than one (or NoExpiration),
+// the items in the cache never expire (by default), and must be deleted
+// manually. If the cleanup interval is less than one, expired items are not
+// deleted from the cache before calling c.DeleteExpired().
+func New[K comparable, V any](defaultExpiration, cleanupInterval time.Duration) *Cache[K, V] {
+	return NewFrom(defaultExpiration, cleanupInterval, make(map[K]Item[V]))
+}
+
+// NewFrom return a new cache with a given default expiration duration and cleanup
+// interval, see New().
+//
+// NewFrom() also accepts an items map which will serve as the underlying map
+// for the cache. Only the cache's methods synchronize access to this map, so it
+// is not recommended to keep any references to the map around after creating a cache.
+func NewFrom[K comparable, V any](defaultExpiration, cleanupInterval time.Duration, items map[K]Item[V]) *Cache[K, V] {
+	if defaultExpiration == 0 {
+		defaultExpiration = -1
+	}
+	c := &cacheOf[K, V]{
+		defaultExpiration: defaultExpiration,
+		items:             items,
+	}
+	// This trick ensures that the janitor goroutine (which--granted it
+	// was enabled--is running DeleteExpired on c forever) does not keep
+	// the returned C object from being garbage collected. When it is
+	// garbage collected, the finalizer stops the janitor goroutine, after
+	// which c can be collected.
+	C := &Cache[K, V]{c}
+	if cleanupInterval > 0 {
+		runJanitor(c, cleanupInterval)
+		runtime.SetFinalizer(C, stopJanitor[K, V])
+	}
+	return C
+}
+
+// OnEvicted sets an (optional) function that is called with the key and value when an
+// item is evicted from the cache. (Including when it is deleted manually, but
+// not when it is overwritten.) Set to nil to disable.
+func (c *Cache[K, V]) OnEvicted(f func(K, V)) *Cache[K, V] {
+	c.setOnEvicted(f)
+	return c
+}
@@ -0,0 +1,179 @@
+package typed
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Cache(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+
+	a, found := tc.Get("a")
+	if found || a != 0 {
+		t.Error("Getting A found value that shouldn't exist:", a)
+	}
+
+	tc.SetDefault("a", 1)
+	tc.SetDefault("b", 2)
+
+	x, found := tc.Get("a")
+	if !found {
+		t.Error("a was not found")
+	}
+	if x+2 != 3 {
+		t.Error("a (which should be 1) plus 2 does not equal 3; value:", x)
+	}
+
+	x = tc.GetOrNew("c", func() int { return 4 }, DefaultExpiration)
+	if x != 4 {
+		t.Error("c (which should be 4) does not equal 4; value:", x)
+	}
+	x = tc.GetOrNew("c", func() int { return 5 }, DefaultExpiration)
+	if x != 4 {
+		t.Error("c was overwritten by GetOrNew; value:", x)
+	}
+	if n := tc.Count(); n != 3 {
+		t.Errorf("Item count is not 3: %d", n)
+	}
+}
+
+func Test_CacheTimes(t *testing.T) {
+	var found bool
+
+	tc := New[string, int](50*time.Millisecond, 1*time.Millisecond)
+	tc.Set("a", 1, DefaultExpiration)
+	tc.Set("b", 2, NoExpiration)
+	tc.Set("c", 3, 20*time.Millisecond)
+
+	<-time.After(25 * time.Millisecond)
+	_, found = tc.Get("c")
+	if found {
+		t.Error("Found c when it should have been automatically deleted")
+	}
+
+	<-time.After(30 * time.Millisecond)
+	_, found = tc.Get("a")
+	if found {
+		t.Error("Found a when it should have been automatically deleted")
+	}
+	_, found = tc.Get("b")
+	if !found {
+		t.Error("Did not find b even though it was set to never expire")
+	}
+}
+
+func Test_SetNXAndSetXX(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+	if _, ok := tc.SetXX("foo", "bar", DefaultExpiration); ok {
+		t.Error("Replaced foo when it shouldn't exist")
+	}
+	if !tc.SetNX("foo", "bar", DefaultExpiration) {
+		t.Error("Couldn't add foo even though it shouldn't exist")
+	}
+	if tc.SetNX("foo", "baz", DefaultExpiration) {
+		t.Error("Successfully added another foo")
+	}
+	old, ok := tc.SetXX("foo", "baz", DefaultExpiration)
+	if !ok || old != "bar" {
+		t.Error("Couldn't replace existing key foo:", old)
+	}
+}
+
+func Test_Upsert(t *testing.T) {
+	tc := New[string, []int](DefaultExpiration, 0)
+	appendCb := func(n int) UpsertCb[[]int] {
+		return func(exist bool, v []int) []int {
+			return append(v, n)
+		}
+	}
+	tc.Upsert("foo", appendCb(1), DefaultExpiration)
+	v := tc.Upsert("foo", appendCb(2), DefaultExpiration)
+	if len(v) != 2 || v[0] != 1 || v[1] != 2 {
+		t.Error("Upsert result is not [1 2]:", v)
+	}
+}
+
+func Test_GetExAndExpire(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	if _, found := tc.GetEx("a", time.Second); found {
+		t.Error("Getting A found value that shouldn't exist")
+	}
+	if tc.Expire("a", time.Second) {
+		t.Error("Expire updated a missing key")
+	}
+	tc.SetDefault("a", 1)
+	if _, expiration, _ := tc.GetWithExpiration("a"); !expiration.IsZero() {
+		t.Error("expiration for a is not a zeroed time")
+	}
+	if v, found := tc.GetEx("a", time.Second); !found || v != 1 {
+		t.Error("Getting A found value that should exist:", v)
+	}
+	if _, expiration, _ := tc.GetWithExpiration("a"); expiration.IsZero() {
+		t.Error("expiration for a is a zeroed time")
+	}
+	if !tc.Expire("a", time.Millisecond) {
+		t.Error("Expire did not update a")
+	}
+	<-time.After(5 * time.Millisecond)
+	if _, found := tc.Get("a"); found {
+		t.Error("Found a when it should have been expired")
+	}
+}
+
+func Test_OnEvicted(t *testing.T) {
+	evicted := map[string]int{}
+	tc := New[string, int](DefaultExpiration, 0).
+		OnEvicted(func(k string, v int) { evicted[k] = v })
+	tc.Set("foo", 1, DefaultExpiration)
+	tc.Set("bar", 2, DefaultExpiration)
+	tc.Set("baz", 3, DefaultExpiration)
+	tc.Set("expired", 4, time.Millisecond)
+
+	tc.Delete("foo")
+	if v, found := tc.GetDel("bar"); !found || v != 2 {
+		t.Error("GetDel bar is not 2:", v)
+	}
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	tc.Clear()
+
+	want := map[string]int{"foo": 1, "bar": 2, "baz": 3, "expired": 4}
+	if len(evicted) != len(want) {
+		t.Fatal("evicted items is not", want, evicted)
+	}
+	for k, v := range want {
+		if evicted[k] != v {
+			t.Errorf("evicted %s is not %d: %d", k, v, evicted[k])
+		}
+	}
+}
+
+func Test_Items(t *testing.T) {
+	tc := NewFrom(DefaultExpiration, 0, map[string]Item[int]{
+		"a":       {Value: 1},
+		"expired": {Value: 2, Expiration: time.Now().Add(-time.Second).UnixNano()},
+	})
+	items := tc.Items()
+	if len(items) != 1 || items["a"].Value != 1 {
+		t.Error("Items is not only a:", items)
+	}
+}
+
+func Test_IncrDecr(t *testing.T) {
+	tc := New[string, float64](DefaultExpiration, 0)
+	if _, err := Incr(tc, "a", 1); err == nil {
+		t.Error("Incr on a missing key did not return an error")
+	}
+	tc.SetDefault("a", 1.5)
+	v, err := Incr(tc, "a", 2)
+	if err != nil || v != 3.5 {
+		t.Error("a is not 3.5:", v, err)
+	}
+	v, err = Decr(tc, "a", 0.5)
+	if err != nil || v != 3 {
+		t.Error("a is not 3:", v, err)
+	}
+	if x, _ := tc.Get("a"); x != 3 {
+		t.Error("stored a is not 3:", x)
+	}
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"sync"
+)
+
+// defaultWatchBuffer is the default capacity of the channel returned by Watch.
+const defaultWatchBuffer = 64
+
+// Op is the operation of an Event.
+type Op uint8
+
+const (
+	// OpSet is a new key set to the cache, Old is nil.
+	OpSet Op = iota
+	// OpReplace is the value of an existing key replaced.
+	OpReplace
+	// OpIncr is the value of an existing key incremented or decremented.
+	OpIncr
+	// OpDelete is a key deleted from the cache, New is nil.
+	OpDelete
+	// OpExpire is an expired key removed from the cache, New is nil.
+	OpExpire
+	// OpEvict is a key evicted by the capacity limits of the cache, New is nil.
+	OpEvict
+	// OpClear is a key removed by Clear, New is nil.
+	OpClear
+)
+
+// String returns the name of the operation.
+func (o Op) String() string {
+	switch o {
+	case OpSet:
+		return "set"
+	case OpReplace:
+		return "replace"
+	case OpIncr:
+		return "incr"
+	case OpDelete:
+		return "delete"
+	case OpExpire:
+		return "expire"
+	case OpEvict:
+		return "evict"
+	case OpClear:
+		return "clear"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change of a key in the cache.
+type Event struct {
+	Op  Op
+	Key string
+	Old any // value before the change, nil for OpSet
+	New any // value after the change, nil if the key was removed
+}
+
+// WithWatchBuffer sets the capacity of the channels returned by Watch, 64 by default.
+func WithWatchBuffer(n int) Option {
+	return func(c *cache) {
+		c.watchBuffer = n
+	}
+}
+
+// watcher is a subscription of Watch. A sharded cache registers the same
+// watcher on every shard, so it guards its channel itself.
+type watcher struct {
+	matches func(string) bool
+	mu      sync.Mutex
+	closed  bool
+	ch      chan Event
+}
+
+func newWatcher(keyOrPrefix string, buffer int) *watcher {
+	if buffer <= 0 {
+		buffer = defaultWatchBuffer
+	}
+	return &watcher{
+		matches: keyMatcher(keyOrPrefix),
+		ch:      make(chan Event, buffer),
+	}
+}
+
+// send delivers the event without blocking, the event is dropped if the
+// channel is full.
+func (w *watcher) send(ev Event) {
+	w.mu.Lock()
+	if !w.closed {
+		select {
+		case w.ch <- ev:
+		default:
+		}
+	}
+	w.mu.Unlock()
+}
+
+func (w *watcher) close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.ch)
+	}
+	w.mu.Unlock()
+}
+
+// Watch returns a channel receiving the events of the keys matching
+// keyOrPrefix, and a function to cancel the watch, which closes the channel.
+// keyOrPrefix is a key prefix or a glob pattern, the same as the match of
+// Scan; an empty keyOrPrefix watches all keys.
+//
+// The events are sent in the order of the changes of each key, without
+// blocking the cache: if the channel is full, the event is dropped, so the
+// receiver should keep up, or use WithWatchBuffer. Only changes of the values
+// are reported, not changes of the expiration only. The channel is also closed
+// when the cache is closed.
+func (c *cache) Watch(keyOrPrefix string) (<-chan Event, func()) {
+	w := newWatcher(keyOrPrefix, c.watchBuffer)
+	c.addWatcher(w)
+	return w.ch, func() {
+		c.removeWatcher(w)
+		w.close()
+	}
+}
+
+func (c *cache) addWatcher(w *watcher) {
+	c.mu.Lock()
+	if c.closed {
+		w.close()
+	} else {
+		if c.watchers == nil {
+			c.watchers = make(map[*watcher]struct{})
+		}
+		c.watchers[w] = struct{}{}
+	}
+	c.mu.Unlock()
+}
+
+func (c *cache) removeWatcher(w *watcher) {
+	c.mu.Lock()
+	delete(c.watchers, w)
+	c.mu.Unlock()
+}
+
+// notify sends the event to the matching watchers.
+// It must be called with c.mu held, so the events of a key are in order.
+func (c *cache) notify(op Op, k string, old, new any) {
+	if len(c.watchers) == 0 {
+		return
+	}
+	ev := Event{Op: op, Key: k, Old: old, New: new}
+	for w := range c.watchers {
+		if w.matches(k) {
+			w.send(ev)
+		}
+	}
+}
+
+// notifyEvicted sends the event of an evicted item, see notify.
+func (c *cache) notifyEvicted(k string, x any, reason EvictReason) {
+	switch reason {
+	case EvictExpired:
+		c.notify(OpExpire, k, x, nil)
+	case EvictDeleted:
+		c.notify(OpDelete, k, x, nil)
+	case EvictCleared:
+		c.notify(OpClear, k, x, nil)
+	case EvictCapacity:
+		c.notify(OpEvict, k, x, nil)
+	case EvictReplaced:
+		// reported by set as OpReplace, with the new value.
+	}
+}
+
+// closeWatchers closes the channels of all watchers.
+// It must be called with c.mu held.
+func (c *cache) closeWatchers() {
+	for w := range c.watchers {
+		w.close()
+	}
+	c.watchers = nil
+}
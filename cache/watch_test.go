@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func receiveEvents(ch <-chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func Test_Watch(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithMaxEntries(2))
+	ch, cancel := tc.Watch("user:")
+	defer cancel()
+
+	tc.Set("user:1", 1, DefaultExpiration)
+	tc.Set("other", "x", DefaultExpiration)
+	tc.Set("user:1", 2, DefaultExpiration)
+	_, _ = tc.IncrInt("user:1", 3)
+	tc.Set("user:2", 10, time.Millisecond)
+	<-time.After(5 * time.Millisecond)
+	tc.DeleteExpired()
+	tc.Set("user:3", 30, DefaultExpiration) // evicts other
+	tc.Set("user:4", 40, DefaultExpiration) // evicts user:1
+	tc.Delete("user:3")
+	tc.Clear()
+
+	want := []Event{
+		{OpSet, "user:1", nil, 1},
+		{OpReplace, "user:1", 1, 2},
+		{OpIncr, "user:1", 2, 5},
+		{OpSet, "user:2", nil, 10},
+		{OpExpire, "user:2", 10, nil},
+		{OpSet, "user:3", nil, 30},
+		{OpEvict, "user:1", 5, nil},
+		{OpSet, "user:4", nil, 40},
+		{OpDelete, "user:3", 30, nil},
+		{OpClear, "user:4", 40, nil},
+	}
+	if got := receiveEvents(ch); !slices.Equal(got, want) {
+		t.Errorf("events are\n%v\nwant\n%v", got, want)
+	}
+}
+
+func Test_WatchGlob(t *testing.T) {
+	tc := New(DefaultExpiration, 0)
+	ch, cancel := tc.Watch("user:*:profile")
+	tc.Set("user:1:profile", 1, DefaultExpiration)
+	tc.Set("user:1:settings", 2, DefaultExpiration)
+	if got := receiveEvents(ch); len(got) != 1 || got[0].Key != "user:1:profile" {
+		t.Error("glob watch did not receive only user:1:profile:", got)
+	}
+
+	cancel()
+	cancel()
+	tc.Set("user:2:profile", 1, DefaultExpiration)
+	if _, ok := <-ch; ok {
+		t.Error("channel is not closed after cancel")
+	}
+}
+
+func Test_WatchBuffer(t *testing.T) {
+	tc := New(DefaultExpiration, 0, WithWatchBuffer(2))
+	ch, cancel := tc.Watch("")
+	defer cancel()
+	for range 5 {
+		tc.Set("a", 1, DefaultExpiration)
+	}
+	if got := receiveEvents(ch); len(got) != 2 || got[0].Op != OpSet || got[1].Op != OpReplace {
+		t.Error("watch did not drop the events over the buffer:", got)
+	}
+}
+
+func Test_WatchClose(t *testing.T) {
+	tc := NewSharded(4, DefaultExpiration, 0)
+	ch, cancel := tc.Watch("")
+	defer cancel()
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tc.Set(k, k, DefaultExpiration)
+	}
+	if err := tc.Close(); err != nil {
+		t.Fatal("Close failed:", err)
+	}
+	tc.Set("e", "e", DefaultExpiration)
+
+	var keys []string
+	for ev := range ch {
+		keys = append(keys, ev.Key)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b", "c", "d"}) {
+		t.Error("watch did not receive the events before Close:", keys)
+	}
+
+	ch, _ = tc.Watch("")
+	if _, ok := <-ch; ok {
+		t.Error("channel of a closed cache is not closed")
+	}
+}
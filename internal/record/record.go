@@ -0,0 +1,182 @@
+// Package record implements the framing of the versioned binary formats, such
+// as the cache snapshots and the topic tree exports:
+//
+//	header: magic | version uvarint | name
+//	entry:  recordEntry | fields
+//	end:    recordEnd | number of entries uvarint
+//
+// where the fields of an entry are defined by the format, and bytes are written
+// as uvarint length followed by the bytes.
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	recordEnd   byte = 0
+	recordEntry byte = 1
+)
+
+// Writer writes the records of a format. The write errors are kept, and
+// returned by End.
+type Writer struct {
+	bw    *bufio.Writer
+	buf   []byte
+	count uint64
+}
+
+// NewWriter returns a Writer to w, and writes the header.
+func NewWriter(w io.Writer, magic string, version uint64, name string) *Writer {
+	rw := &Writer{
+		bw:  bufio.NewWriter(w),
+		buf: make([]byte, 0, binary.MaxVarintLen64),
+	}
+	_, _ = rw.bw.WriteString(magic)
+	rw.Uvarint(version)
+	rw.String(name)
+	return rw
+}
+
+// Entry starts an entry, whose fields are written next.
+func (w *Writer) Entry() {
+	_ = w.bw.WriteByte(recordEntry)
+	w.count++
+}
+
+// Bytes writes a bytes field.
+func (w *Writer) Bytes(b []byte) {
+	w.Uvarint(uint64(len(b)))
+	_, _ = w.bw.Write(b)
+}
+
+// String writes a string field.
+func (w *Writer) String(s string) {
+	w.Uvarint(uint64(len(s)))
+	_, _ = w.bw.WriteString(s)
+}
+
+// Varint writes a varint field.
+func (w *Writer) Varint(x int64) {
+	w.buf = binary.AppendVarint(w.buf[:0], x)
+	_, _ = w.bw.Write(w.buf)
+}
+
+// Uvarint writes a uvarint field.
+func (w *Writer) Uvarint(x uint64) {
+	w.buf = binary.AppendUvarint(w.buf[:0], x)
+	_, _ = w.bw.Write(w.buf)
+}
+
+// End writes the end record and flushes the writes. Returns the first write
+// error.
+func (w *Writer) End() error {
+	_ = w.bw.WriteByte(recordEnd)
+	w.Uvarint(w.count)
+	// bufio.Writer keeps the first write error, and returns it here.
+	return w.bw.Flush()
+}
+
+// Reader reads the records written by a Writer. A truncated or corrupted
+// input is reported as the format error of the Reader.
+type Reader struct {
+	br        *bufio.Reader
+	magic     string
+	errFormat error
+	count     uint64
+}
+
+// NewReader returns a Reader of r, for the format starting with magic, which
+// reports an invalid input as errFormat.
+func NewReader(r io.Reader, magic string, errFormat error) *Reader {
+	return &Reader{
+		br:        bufio.NewReader(r),
+		magic:     magic,
+		errFormat: errFormat,
+	}
+}
+
+// Version reads the magic and the version of the header, the name is read
+// next with String, if the version is supported.
+func (r *Reader) Version() (uint64, error) {
+	magic := make([]byte, len(r.magic))
+	if _, err := io.ReadFull(r.br, magic); err != nil || string(magic) != r.magic {
+		return 0, r.errFormat
+	}
+	return r.Uvarint()
+}
+
+// Next reads the start of the next record. Returns true for an entry, whose
+// fields are read next, or false at the end record, once the number of
+// entries read is checked.
+func (r *Reader) Next() (bool, error) {
+	kind, err := r.br.ReadByte()
+	if err != nil {
+		return false, r.formatError(err)
+	}
+	switch kind {
+	case recordEntry:
+		r.count++
+		return true, nil
+	case recordEnd:
+		n, err := r.Uvarint()
+		if err != nil {
+			return false, err
+		}
+		if n != r.count {
+			return false, r.errFormat
+		}
+		return false, nil
+	default:
+		return false, r.errFormat
+	}
+}
+
+// Bytes reads a bytes field.
+func (r *Reader) Bytes() ([]byte, error) {
+	n, err := r.Uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt32 {
+		return nil, r.errFormat
+	}
+	// grows with the data read, so a corrupted length can not allocate
+	// a huge buffer up front.
+	var b bytes.Buffer
+	if _, err = io.CopyN(&b, r.br, int64(n)); err != nil {
+		return nil, r.formatError(err)
+	}
+	return b.Bytes(), nil
+}
+
+// String reads a string field.
+func (r *Reader) String() (string, error) {
+	b, err := r.Bytes()
+	return string(b), err
+}
+
+// Varint reads a varint field.
+func (r *Reader) Varint() (int64, error) {
+	x, err := binary.ReadVarint(r.br)
+	return x, r.formatError(err)
+}
+
+// Uvarint reads a uvarint field.
+func (r *Reader) Uvarint() (uint64, error) {
+	x, err := binary.ReadUvarint(r.br)
+	return x, r.formatError(err)
+}
+
+// formatError reports a truncated input as the format error.
+func (r *Reader) formatError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return r.errFormat
+	}
+	return err
+}
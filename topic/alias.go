@@ -0,0 +1,72 @@
+package topic
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTopicAlias is returned by Aliases.Resolve for an alias which is 0,
+// exceeds the maximum, or was not set.
+var ErrTopicAlias = errors.New("invalid topic alias")
+
+// Aliases maps the MQTT 5 topic aliases of one direction of a connection to
+// topics. The receiver of the PUBLISH packets resolves them with Resolve, and
+// the sender assigns them with Alias. The aliases are only valid for the
+// connection, a new Aliases must be used for every connection.
+type Aliases struct {
+	mu      sync.Mutex
+	max     uint16
+	topics  map[uint16]string // received aliases
+	aliases map[string]uint16 // sent aliases
+}
+
+// NewAliases returns a new Aliases with the topic alias maximum of the
+// connection. With a maximum of 0, topic aliases are not used.
+func NewAliases(maximum uint16) *Aliases {
+	return &Aliases{
+		max:     maximum,
+		topics:  make(map[uint16]string),
+		aliases: make(map[string]uint16),
+	}
+}
+
+// Resolve returns the topic of a received PUBLISH packet with the topic and
+// the alias. A non-empty topic (re)sets the alias, an empty topic is replaced
+// by the topic of the alias. Returns ErrTopicAlias if the alias is invalid,
+// or if the topic is empty and the alias was not set.
+func (a *Aliases) Resolve(topic string, alias uint16) (string, error) {
+	if alias == 0 || alias > a.max {
+		return "", ErrTopicAlias
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if topic != "" {
+		a.topics[alias] = topic
+		return topic, nil
+	}
+	topic, ok := a.topics[alias]
+	if !ok {
+		return "", ErrTopicAlias
+	}
+	return topic, nil
+}
+
+// Alias returns the alias to send with a PUBLISH packet of the topic, and a
+// bool indicating whether the receiver already knows it, so that the topic
+// may be sent empty. A new alias is assigned to the topic until the maximum
+// is reached, after which the topics without an alias get 0, meaning no
+// alias is sent.
+func (a *Aliases) Alias(topic string) (uint16, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if alias, ok := a.aliases[topic]; ok {
+		return alias, true
+	}
+	if len(a.aliases) >= int(a.max) {
+		return 0, false
+	}
+	alias := uint16(len(a.aliases) + 1)
+	a.aliases[topic] = alias
+	return alias, false
+}
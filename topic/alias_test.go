@@ -0,0 +1,53 @@
+package topic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AliasesResolve(t *testing.T) {
+	a := NewAliases(2)
+
+	topic, err := a.Resolve("sport/tennis", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "sport/tennis", topic)
+	topic, err = a.Resolve("", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "sport/tennis", topic)
+
+	_, err = a.Resolve("sport/golf", 1)
+	require.NoError(t, err)
+	topic, err = a.Resolve("", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "sport/golf", topic)
+
+	_, err = a.Resolve("", 2)
+	assert.Equal(t, ErrTopicAlias, err)
+	_, err = a.Resolve("sport", 0)
+	assert.Equal(t, ErrTopicAlias, err)
+	_, err = a.Resolve("sport", 3)
+	assert.Equal(t, ErrTopicAlias, err)
+}
+
+func Test_AliasesAlias(t *testing.T) {
+	a := NewAliases(2)
+
+	alias, known := a.Alias("a")
+	assert.Equal(t, uint16(1), alias)
+	assert.False(t, known)
+	alias, known = a.Alias("a")
+	assert.Equal(t, uint16(1), alias)
+	assert.True(t, known)
+
+	alias, _ = a.Alias("b")
+	assert.Equal(t, uint16(2), alias)
+	alias, known = a.Alias("c")
+	assert.Equal(t, uint16(0), alias)
+	assert.False(t, known)
+
+	alias, known = NewAliases(0).Alias("a")
+	assert.Equal(t, uint16(0), alias)
+	assert.False(t, known)
+}
@@ -0,0 +1,203 @@
+package topic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+
+	"github.com/thinkgos/proc/internal/record"
+)
+
+// ExportVersion is the version of the format written by Export.
+const ExportVersion = 1
+
+// exportMagic starts every export.
+const exportMagic = "PTTR"
+
+var (
+	ErrExportFormat  = errors.New("topic: invalid export format")
+	ErrExportVersion = errors.New("topic: unsupported export version")
+	ErrExportCodec   = errors.New("topic: export codec mismatch")
+	// ErrExportSeparator is returned by Import if the export was written by
+	// a tree with another separator.
+	ErrExportSeparator = errors.New("topic: export separator mismatch")
+)
+
+// ValueCodec encodes and decodes the values of a tree for Export and Import.
+type ValueCodec[V any] interface {
+	// Name identifies the codec. It is written to the export header, and an
+	// export can only be imported with a codec of the same name.
+	Name() string
+	// Marshal encodes the value.
+	Marshal(v V) ([]byte, error)
+	// Unmarshal decodes the value.
+	Unmarshal(data []byte) (V, error)
+}
+
+// JSONCodec encodes values of type V with encoding/json.
+type JSONCodec[V any] struct{}
+
+// Name implements ValueCodec.
+func (JSONCodec[V]) Name() string { return "json" }
+
+// Marshal implements ValueCodec.
+func (JSONCodec[V]) Marshal(v V) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements ValueCodec.
+func (JSONCodec[V]) Unmarshal(data []byte) (V, error) {
+	var v V
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// Walk returns an iterator over the (filter, value) pairs stored in the tree,
+// in the order of the filters. The values of a shared subscription are
+// yielded with their "$share/<group>/<filter>" filter. As with MatchSeq, the
+// tree must not be modified by the loop body, unless it is in copy-on-write
+// mode.
+func (t *TreeOf[V]) Walk() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		root := t.rlock()
+		defer t.runlock()
+
+		t.walk(root, nil, true, yield)
+	}
+}
+
+// walk calls fn with the filters and values of the children of the node at
+// path, until fn returns false. Returns false if stopped.
+func (t *TreeOf[V]) walk(node *node[V], path []byte, top bool, fn func(string, V) bool) bool {
+	for _, segment := range slices.Sorted(maps.Keys(node.children)) {
+		child := node.children[segment]
+		filter := path
+		if !top {
+			filter = append(filter, t.separator...)
+		}
+		filter = append(filter, segment...)
+
+		for _, v := range child.values {
+			if !fn(string(filter), v) {
+				return false
+			}
+		}
+		for _, group := range slices.Sorted(maps.Keys(child.shared)) {
+			shared := SharePrefix + t.separator + group + t.separator + string(filter)
+			for _, v := range child.shared[group].values {
+				if !fn(shared, v) {
+					return false
+				}
+			}
+		}
+		if !t.walk(child, filter, false, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Export writes every filter and value of the tree to w, with the values
+// encoded by codec, so that the tree can be restored by Import. The export
+// starts with a versioned header naming codec and the separator of the tree.
+// The filters and values are copied under the lock of the tree, and encoded
+// and written after it is released.
+//
+// The format is:
+//
+//	header: magic "PTTR" | version uvarint | codec name | separator
+//	entry:  recordEntry | filter | value
+//	end:    recordEnd | number of entries uvarint
+//
+// where strings and values are written as uvarint length followed by the bytes.
+func (t *TreeOf[V]) Export(w io.Writer, codec ValueCodec[V]) error {
+	type entry struct {
+		filter string
+		value  V
+	}
+	var entries []entry
+	for filter, v := range t.Walk() {
+		entries = append(entries, entry{filter, v})
+	}
+
+	rw := record.NewWriter(w, exportMagic, ExportVersion, codec.Name())
+	rw.String(t.separator)
+	for _, e := range entries {
+		data, err := codec.Marshal(e.value)
+		if err != nil {
+			return fmt.Errorf("topic: export filter %q: %w", e.filter, err)
+		}
+		rw.Entry()
+		rw.String(e.filter)
+		rw.Bytes(data)
+	}
+	return rw.End()
+}
+
+// Import reads an export written by Export, with the values decoded by codec,
+// and adds its filters and values to the tree. The export is read completely
+// before the tree is modified, so that a failed import leaves the tree
+// unchanged. Returns ErrExportSeparator if the export was written by a tree
+// with another separator. Returns the number of values read.
+func (t *TreeOf[V]) Import(r io.Reader, codec ValueCodec[V]) (int, error) {
+	rr := record.NewReader(r, exportMagic, ErrExportFormat)
+	version, err := rr.Version()
+	if err != nil {
+		return 0, err
+	}
+	if version != ExportVersion {
+		return 0, fmt.Errorf("%w: %d", ErrExportVersion, version)
+	}
+	name, err := rr.String()
+	if err != nil {
+		return 0, err
+	}
+	if name != codec.Name() {
+		return 0, fmt.Errorf("%w: export %q, codec %q", ErrExportCodec, name, codec.Name())
+	}
+	separator, err := rr.String()
+	if err != nil {
+		return 0, err
+	}
+	if separator != t.separator {
+		return 0, fmt.Errorf("%w: export %q, tree %q", ErrExportSeparator, separator, t.separator)
+	}
+
+	type entry struct {
+		filter string
+		value  V
+	}
+	var entries []entry
+	for {
+		more, err := rr.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !more {
+			break
+		}
+		filter, err := rr.String()
+		if err != nil {
+			return 0, err
+		}
+		data, err := rr.Bytes()
+		if err != nil {
+			return 0, err
+		}
+		v, err := codec.Unmarshal(data)
+		if err != nil {
+			return 0, fmt.Errorf("topic: import filter %q: %w", filter, err)
+		}
+		entries = append(entries, entry{filter, v})
+	}
+
+	t.update(func(root *node[V]) {
+		for _, e := range entries {
+			group, filter := t.shared(e.filter)
+			t.add(group, e.value, false, filter, root)
+		}
+	})
+	return len(entries), nil
+}
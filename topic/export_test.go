@@ -0,0 +1,141 @@
+package topic
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingCodec struct{ JSONCodec[int] }
+
+func (failingCodec) Marshal(int) ([]byte, error) { return nil, errors.New("marshal failed") }
+
+func Test_TreeWalk(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("", 2)
+	tree.Add("/foo", 3)
+	tree.Add("foo", 4)
+	tree.Add("foo/#", 5)
+	tree.Add("$share/g/foo/+", 6)
+	tree.Add("foo/bar", 7)
+
+	var filters []string
+	var values []int
+	for filter, v := range tree.Walk() {
+		filters = append(filters, filter)
+		values = append(values, v)
+	}
+	assert.Equal(t, []string{"", "/foo", "foo", "foo/#", "$share/g/foo/+", "foo/bar", "foo/bar"}, filters)
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 1, 7}, values)
+
+	n := 0
+	for range tree.Walk() {
+		n++
+		break
+	}
+	assert.Equal(t, 1, n)
+}
+
+func Test_TreeExportImport(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/+", 2)
+	tree.Add("$share/g/foo/#", 3)
+	tree.Add("$share/g/foo/#", 4)
+
+	var buf bytes.Buffer
+	require.NoError(t, tree.Export(&buf, JSONCodec[int]{}))
+	data := buf.Bytes()
+
+	restored := NewStandardTreeOf(WithCopyOnWrite[int]())
+	restored.Add("baz", 5)
+	n, err := restored.Import(bytes.NewReader(data), JSONCodec[int]{})
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, 5, restored.Count())
+	assert.Equal(t, []int{3, 4}, restored.Get("$share/g/foo/#"))
+	assert.Equal(t, []int{2}, restored.Get("foo/+"))
+
+	// errors leave the tree unchanged
+	empty := NewStandardTreeOf[int]()
+	_, err = empty.Import(bytes.NewReader(data[:len(data)-3]), JSONCodec[int]{})
+	assert.ErrorIs(t, err, ErrExportFormat)
+	_, err = empty.Import(bytes.NewReader([]byte("PTTR\x02")), JSONCodec[int]{})
+	assert.ErrorIs(t, err, ErrExportVersion)
+	_, err = empty.Import(bytes.NewReader([]byte("nope")), JSONCodec[int]{})
+	assert.ErrorIs(t, err, ErrExportFormat)
+	_, err = NewStandardTreeOf[string]().Import(bytes.NewReader(data), JSONCodec[string]{})
+	assert.Error(t, err)
+	assert.Equal(t, 0, empty.Count())
+
+	assert.Error(t, tree.Export(&buf, failingCodec{}))
+}
+
+type hexCodec struct{}
+
+func (hexCodec) Name() string                  { return "hex" }
+func (hexCodec) Marshal(v int) ([]byte, error) { return []byte(strconv.FormatInt(int64(v), 16)), nil }
+func (hexCodec) Unmarshal(data []byte) (int, error) {
+	v, err := strconv.ParseInt(string(data), 16, 64)
+	return int(v), err
+}
+
+func Test_TreeImportCodecMismatch(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+	tree.Add("foo", 255)
+
+	var buf bytes.Buffer
+	require.NoError(t, tree.Export(&buf, hexCodec{}))
+	data := buf.Bytes()
+
+	_, err := NewStandardTreeOf[int]().Import(bytes.NewReader(data), JSONCodec[int]{})
+	assert.ErrorIs(t, err, ErrExportCodec)
+
+	restored := NewStandardTreeOf[int]()
+	_, err = restored.Import(bytes.NewReader(data), hexCodec{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{255}, restored.Get("foo"))
+}
+
+func Test_TreeImportSeparatorMismatch(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+	tree.Add("a/b", 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, tree.Export(&buf, JSONCodec[int]{}))
+
+	dotted := NewTreeOf[int](".", "*", ">")
+	_, err := dotted.Import(bytes.NewReader(buf.Bytes()), JSONCodec[int]{})
+	assert.ErrorIs(t, err, ErrExportSeparator)
+	assert.Equal(t, 0, dotted.Count())
+}
+
+// treeCodec modifies the tree while the values are encoded.
+type treeCodec struct {
+	JSONCodec[int]
+	tree *TreeOf[int]
+}
+
+func (c treeCodec) Marshal(v int) ([]byte, error) {
+	c.tree.Add("touched", v)
+	return c.JSONCodec.Marshal(v)
+}
+
+func Test_TreeExportOutsideLock(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+	tree.Add("a", 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, tree.Export(&buf, treeCodec{tree: tree}))
+
+	restored := NewStandardTreeOf[int]()
+	n, err := restored.Import(&buf, JSONCodec[int]{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
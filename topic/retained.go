@@ -0,0 +1,299 @@
+package topic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/thinkgos/proc/go/heap"
+	"github.com/thinkgos/proc/go/list"
+)
+
+// RetainedMessage is a retained message of a RetainedStore.
+type RetainedMessage[V any] struct {
+	Topic      string
+	Value      V
+	Expiration time.Time // zero if the message never expires
+}
+
+// RetainedStore stores the last retained message of each topic, as a broker
+// does to deliver them to a new subscriber. The topics are normalized with
+// Parse and may not contain wildcards, while Search accepts a wildcard filter.
+// Messages can expire, and the store can be bounded in size, in which case
+// storing a message for a new topic drops the oldest message. It is safe for
+// concurrent use.
+type RetainedStore[V any] struct {
+	mu      sync.RWMutex
+	root    *retainedNode[V]
+	order   *list.List[*retained[V]] // by time stored, oldest first
+	expiry  retainedHeap[V]
+	maxSize int
+}
+
+type retainedNode[V any] struct {
+	children map[string]*retainedNode[V]
+	msg      *list.Element[*retained[V]]
+}
+
+type retained[V any] struct {
+	topic      string
+	value      V
+	expiration int64 // unix nano, 0 if the message never expires
+	index      int   // index in the expiry heap, -1 if not in it
+}
+
+func (r *retained[V]) expired(now int64) bool {
+	return r.expiration > 0 && now > r.expiration
+}
+
+func (r *retained[V]) message() RetainedMessage[V] {
+	m := RetainedMessage[V]{Topic: r.topic, Value: r.value}
+	if r.expiration > 0 {
+		m.Expiration = time.Unix(0, r.expiration)
+	}
+	return m
+}
+
+// retainedHeap is a min-heap of the messages with an expiration.
+type retainedHeap[V any] []*retained[V]
+
+func (h retainedHeap[V]) Len() int           { return len(h) }
+func (h retainedHeap[V]) Less(i, j int) bool { return h[i].expiration < h[j].expiration }
+func (h retainedHeap[V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *retainedHeap[V]) Push(r *retained[V]) {
+	r.index = len(*h)
+	*h = append(*h, r)
+}
+func (h *retainedHeap[V]) Pop() *retained[V] {
+	old := *h
+	n := len(old)
+	r := old[n-1]
+	old[n-1] = nil
+	r.index = -1
+	*h = old[:n-1]
+	return r
+}
+
+func newRetainedNode[V any]() *retainedNode[V] {
+	return &retainedNode[V]{
+		children: make(map[string]*retainedNode[V]),
+	}
+}
+
+// NewRetainedStore returns a new RetainedStore holding at most maxSize
+// messages. If maxSize <= 0, the store is unbounded.
+func NewRetainedStore[V any](maxSize int) *RetainedStore[V] {
+	return &RetainedStore[V]{
+		root:    newRetainedNode[V](),
+		order:   list.New[*retained[V]](),
+		maxSize: maxSize,
+	}
+}
+
+// Set stores the value as the retained message of the topic, replacing the
+// previous one. If ttl > 0, the message expires after ttl. Returns an error if
+// the topic is invalid or contains wildcards.
+func (s *RetainedStore[V]) Set(topic string, value V, ttl time.Duration) error {
+	topic, err := Parse(topic, false)
+	if err != nil {
+		return err
+	}
+
+	r := &retained[V]{topic: topic, value: value, index: -1}
+	now := time.Now().UnixNano()
+	if ttl > 0 {
+		r.expiration = now + int64(ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteExpired(now)
+
+	// make room for the message of a new topic, before growing the tree as
+	// the deletion shrinks it
+	if node := s.node(topic, false); node != nil && node.msg != nil {
+		s.unlink(node.msg)
+	} else if s.maxSize > 0 && s.order.Len() >= s.maxSize {
+		s.delete(s.order.Front().Value.topic)
+	}
+
+	s.node(topic, true).msg = s.order.PushBack(r)
+	if r.expiration > 0 {
+		heap.Push(&s.expiry, r)
+	}
+	return nil
+}
+
+// Get returns the retained message of the topic, and a bool indicating whether
+// an unexpired message was found.
+func (s *RetainedStore[V]) Get(topic string) (RetainedMessage[V], bool) {
+	topic, err := Parse(topic, false)
+	if err != nil {
+		return RetainedMessage[V]{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node := s.node(topic, false)
+	if node == nil || node.msg == nil || node.msg.Value.expired(time.Now().UnixNano()) {
+		return RetainedMessage[V]{}, false
+	}
+	return node.msg.Value.message(), true
+}
+
+// Delete deletes the retained message of the topic. Returns whether a message
+// was deleted.
+func (s *RetainedStore[V]) Delete(topic string) bool {
+	topic, err := Parse(topic, false)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.delete(topic)
+}
+
+// Search returns the unexpired retained messages whose topic matches the
+// filter, which may contain wildcards. As required by MQTT, wildcards at the
+// first level do not match topics starting with "$". Returns an error if the
+// filter is invalid.
+func (s *RetainedStore[V]) Search(filter string) ([]RetainedMessage[V], error) {
+	filter, err := Parse(filter, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []RetainedMessage[V]
+	s.search(filter, s.root, true, time.Now().UnixNano(), func(r *retained[V]) {
+		list = append(list, r.message())
+	})
+	return list, nil
+}
+
+func (s *RetainedStore[V]) search(filter string, node *retainedNode[V], top bool, now int64, fn func(*retained[V])) {
+	// add the message of the topic
+	if filter == topicEnd {
+		if node.msg != nil && !node.msg.Value.expired(now) {
+			fn(node.msg.Value)
+		}
+		return
+	}
+
+	switch segment := topicSegment(filter, "/"); segment {
+	case "#":
+		// match the parent level and all levels below
+		if node.msg != nil && !node.msg.Value.expired(now) {
+			fn(node.msg.Value)
+		}
+		for key, child := range node.children {
+			if top && isSystem(key) {
+				continue
+			}
+			s.search(filter, child, false, now, fn)
+		}
+	case "+":
+		for key, child := range node.children {
+			if top && isSystem(key) {
+				continue
+			}
+			s.search(topicShorten(filter, "/"), child, false, now, fn)
+		}
+	default:
+		if child, ok := node.children[segment]; ok {
+			s.search(topicShorten(filter, "/"), child, false, now, fn)
+		}
+	}
+}
+
+// Len returns the number of messages in the store, including the expired
+// messages which have not yet been deleted.
+func (s *RetainedStore[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.order.Len()
+}
+
+// DeleteExpired deletes the expired messages, and returns their number.
+// Expired messages are never returned, and are also deleted when a message
+// is stored.
+func (s *RetainedStore[V]) DeleteExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteExpired(time.Now().UnixNano())
+}
+
+func (s *RetainedStore[V]) deleteExpired(now int64) int {
+	n := 0
+	for len(s.expiry) > 0 && s.expiry[0].expired(now) {
+		s.delete(s.expiry[0].topic)
+		n++
+	}
+	return n
+}
+
+// node returns the node of the normalized topic. If create is set, the missing
+// nodes are created, otherwise nil is returned if the node does not exist.
+func (s *RetainedStore[V]) node(topic string, create bool) *retainedNode[V] {
+	node := s.root
+	for remainder := topic; remainder != topicEnd; remainder = topicShorten(remainder, "/") {
+		segment := topicSegment(remainder, "/")
+		child, ok := node.children[segment]
+		if !ok {
+			if !create {
+				return nil
+			}
+			child = newRetainedNode[V]()
+			node.children[segment] = child
+		}
+		node = child
+	}
+	return node
+}
+
+// delete deletes the message of the normalized topic, and the nodes left
+// empty.
+func (s *RetainedStore[V]) delete(topic string) bool {
+	var deleted bool
+	var remove func(remainder string, node *retainedNode[V]) bool
+	remove = func(remainder string, node *retainedNode[V]) bool {
+		if remainder == topicEnd {
+			if node.msg != nil {
+				s.unlink(node.msg)
+				node.msg = nil
+				deleted = true
+			}
+		} else {
+			segment := topicSegment(remainder, "/")
+			child, ok := node.children[segment]
+			if !ok {
+				return false
+			}
+			if remove(topicShorten(remainder, "/"), child) {
+				delete(node.children, segment)
+			}
+		}
+		return node.msg == nil && len(node.children) == 0
+	}
+	remove(topic, s.root)
+	return deleted
+}
+
+// unlink removes the message from the order and the expiry heap.
+func (s *RetainedStore[V]) unlink(e *list.Element[*retained[V]]) {
+	r := s.order.Remove(e)
+	if r.index >= 0 {
+		heap.Remove(&s.expiry, r.index)
+	}
+}
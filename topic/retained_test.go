@@ -0,0 +1,114 @@
+package topic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func retainedTopics(messages []RetainedMessage[int]) []string {
+	topics := make([]string, 0, len(messages))
+	for _, m := range messages {
+		topics = append(topics, m.Topic)
+	}
+	return topics
+}
+
+func Test_RetainedStore(t *testing.T) {
+	store := NewRetainedStore[int](0)
+
+	require.NoError(t, store.Set("sport/tennis", 1, 0))
+	require.NoError(t, store.Set("sport//tennis/player1/", 2, 0))
+	require.NoError(t, store.Set("sport/tennis/player2", 3, 0))
+	require.NoError(t, store.Set("sport", 4, 0))
+	require.NoError(t, store.Set("$SYS/uptime", 5, 0))
+	require.NoError(t, store.Set("sport/tennis", 6, 0))
+	require.Equal(t, ErrWildcards, store.Set("sport/+", 7, 0))
+	require.Equal(t, ErrZeroLength, store.Set("/", 7, 0))
+	assert.Equal(t, 5, store.Len())
+
+	m, ok := store.Get("sport/tennis/player1")
+	assert.True(t, ok)
+	assert.Equal(t, RetainedMessage[int]{Topic: "sport/tennis/player1", Value: 2}, m)
+	m, _ = store.Get("sport/tennis")
+	assert.Equal(t, 6, m.Value)
+
+	tests := map[string][]string{
+		"sport/#":          {"sport", "sport/tennis", "sport/tennis/player1", "sport/tennis/player2"},
+		"sport/tennis/+":   {"sport/tennis/player1", "sport/tennis/player2"},
+		"sport/+":          {"sport/tennis"},
+		"+/tennis/player1": {"sport/tennis/player1"},
+		"#":                {"sport", "sport/tennis", "sport/tennis/player1", "sport/tennis/player2"},
+		"+":                {"sport"},
+		"$SYS/#":           {"$SYS/uptime"},
+		"sport/golf":       {},
+	}
+	for filter, topics := range tests {
+		messages, err := store.Search(filter)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, topics, retainedTopics(messages), filter)
+	}
+	_, err := store.Search("sport/#/player1")
+	assert.Equal(t, ErrWildcards, err)
+
+	assert.True(t, store.Delete("sport/tennis"))
+	assert.False(t, store.Delete("sport/tennis"))
+	assert.False(t, store.Delete("sport/golf"))
+	_, ok = store.Get("sport/tennis")
+	assert.False(t, ok)
+	assert.True(t, store.Delete("sport/tennis/player1"))
+	assert.True(t, store.Delete("sport/tennis/player2"))
+	assert.Empty(t, store.root.children["sport"].children)
+}
+
+func Test_RetainedStoreExpiration(t *testing.T) {
+	store := NewRetainedStore[int](0)
+
+	require.NoError(t, store.Set("a", 1, 5*time.Millisecond))
+	require.NoError(t, store.Set("b", 2, time.Hour))
+	require.NoError(t, store.Set("c", 3, 0))
+
+	m, _ := store.Get("b")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), m.Expiration, time.Minute)
+
+	<-time.After(15 * time.Millisecond)
+	_, ok := store.Get("a")
+	assert.False(t, ok)
+	messages, _ := store.Search("#")
+	assert.ElementsMatch(t, []string{"b", "c"}, retainedTopics(messages))
+
+	assert.Equal(t, 3, store.Len())
+	assert.Equal(t, 1, store.DeleteExpired())
+	assert.Equal(t, 2, store.Len())
+
+	// replacing a message replaces its expiration
+	require.NoError(t, store.Set("b", 2, 0))
+	<-time.After(time.Millisecond)
+	assert.Equal(t, 0, store.DeleteExpired())
+	assert.Empty(t, store.expiry)
+}
+
+func Test_RetainedStoreMaxSize(t *testing.T) {
+	store := NewRetainedStore[int](2)
+
+	require.NoError(t, store.Set("a", 1, 0))
+	require.NoError(t, store.Set("a/b", 2, 0))
+	require.NoError(t, store.Set("a", 3, 0))
+	require.NoError(t, store.Set("c", 4, 0)) // drops a/b
+	assert.Equal(t, 2, store.Len())
+	_, ok := store.Get("a/b")
+	assert.False(t, ok)
+
+	require.NoError(t, store.Set("a/b", 5, 0)) // drops a
+	messages, _ := store.Search("#")
+	assert.ElementsMatch(t, []string{"a/b", "c"}, retainedTopics(messages))
+
+	// expired messages are dropped before the oldest
+	require.NoError(t, store.Set("d", 6, time.Millisecond)) // drops c
+	<-time.After(5 * time.Millisecond)
+	require.NoError(t, store.Set("e", 7, 0)) // drops d
+	messages, _ = store.Search("#")
+	assert.ElementsMatch(t, []string{"a/b", "e"}, retainedTopics(messages))
+}
@@ -0,0 +1,178 @@
+package topic
+
+import (
+	"errors"
+	"iter"
+)
+
+// ErrQoS is returned by Subscriptions.Subscribe for a QoS greater than 2.
+var ErrQoS = errors.New("invalid QoS")
+
+// SubscriptionOptions are the MQTT 5 options of a subscription.
+type SubscriptionOptions struct {
+	// QoS is the maximum QoS of the messages sent to the subscriber.
+	QoS byte
+	// NoLocal does not send the messages published by the subscriber itself.
+	NoLocal bool
+	// RetainAsPublished keeps the retain flag of the messages as published.
+	RetainAsPublished bool
+	// SubscriptionID is the subscription identifier, 0 if none.
+	SubscriptionID uint32
+}
+
+// Subscription is the subscription of a subscriber to a filter.
+type Subscription[S comparable] struct {
+	Subscriber S
+	// Filter is the normalized filter, including the "$share/<group>/" prefix
+	// of a shared subscription.
+	Filter string
+	SubscriptionOptions
+}
+
+// SubscriberMatch is a subscriber matched by a topic, with all of its
+// subscriptions whose filter matches.
+type SubscriberMatch[S comparable] struct {
+	Subscriber    S
+	Subscriptions []Subscription[S]
+	// QoS is the maximum QoS of the subscriptions, which the message is
+	// delivered with, as MQTT 5 requires for overlapping subscriptions.
+	QoS byte
+}
+
+// SubscriptionIDs returns the subscription identifiers of the subscriptions,
+// which MQTT 5 requires to be sent with the message.
+func (m SubscriberMatch[S]) SubscriptionIDs() []uint32 {
+	var ids []uint32
+	for _, s := range m.Subscriptions {
+		if s.SubscriptionID != 0 {
+			ids = append(ids, s.SubscriptionID)
+		}
+	}
+	return ids
+}
+
+// Subscriptions is a thread-safe index of the subscriptions of subscribers,
+// e.g. client ids, with their options. In contrast to a Tree of the
+// subscribers, Match keeps which filters matched and their options. Shared
+// subscriptions are supported as in Tree.
+type Subscriptions[S comparable] struct {
+	tree *TreeOf[Subscription[S]]
+}
+
+// NewSubscriptions returns a new Subscriptions using the standard MQTT
+// separator and wildcards. The options configure the underlying tree, e.g.
+// WithCopyOnWrite.
+func NewSubscriptions[S comparable](opts ...TreeOption[Subscription[S]]) *Subscriptions[S] {
+	opts = append(opts, WithKey(func(s Subscription[S]) S { return s.Subscriber }))
+	return &Subscriptions[S]{
+		tree: NewStandardTreeOf(opts...),
+	}
+}
+
+// Subscribe subscribes the subscriber to the filter, which may be a shared
+// subscription, with the options. An existing subscription of the subscriber
+// to the filter is replaced. Returns an error if the filter is invalid,
+// ErrQoS for a QoS greater than 2, or ErrSharedSubscription for a shared
+// subscription with NoLocal, which MQTT forbids.
+func (s *Subscriptions[S]) Subscribe(filter string, subscriber S, opts SubscriptionOptions) error {
+	filter, shared, err := parseFilter(filter)
+	if err != nil {
+		return err
+	}
+	if opts.QoS > 2 {
+		return ErrQoS
+	}
+	if shared && opts.NoLocal {
+		return ErrSharedSubscription
+	}
+
+	s.tree.put(filter, Subscription[S]{
+		Subscriber:          subscriber,
+		Filter:              filter,
+		SubscriptionOptions: opts,
+	})
+	return nil
+}
+
+// Unsubscribe removes the subscription of the subscriber to the filter.
+func (s *Subscriptions[S]) Unsubscribe(filter string, subscriber S) {
+	filter, _, err := parseFilter(filter)
+	if err != nil {
+		return
+	}
+
+	s.tree.Remove(filter, Subscription[S]{Subscriber: subscriber})
+}
+
+// UnsubscribeAll removes all subscriptions of the subscriber.
+func (s *Subscriptions[S]) UnsubscribeAll(subscriber S) {
+	s.tree.Clear(Subscription[S]{Subscriber: subscriber})
+}
+
+// Get returns the subscription of the subscriber to the filter, and a bool
+// indicating whether it was found.
+func (s *Subscriptions[S]) Get(filter string, subscriber S) (Subscription[S], bool) {
+	filter, _, err := parseFilter(filter)
+	if err != nil {
+		return Subscription[S]{}, false
+	}
+
+	return s.tree.find(filter, func(sub Subscription[S]) bool {
+		return sub.Subscriber == subscriber
+	})
+}
+
+// Match returns the subscribers of the topic, each with the subscriptions
+// whose filter matches and their maximum QoS. Of every shared subscription
+// that matches, only one subscriber is returned.
+func (s *Subscriptions[S]) Match(topic string) []SubscriberMatch[S] {
+	return collectMatches(s.tree.MatchSeq(topic), func(Subscription[S]) bool { return true })
+}
+
+// MatchFrom behaves similar to Match for a message published by publisher,
+// skipping the NoLocal subscriptions of the publisher.
+func (s *Subscriptions[S]) MatchFrom(topic string, publisher S) []SubscriberMatch[S] {
+	return collectMatches(s.tree.MatchSeq(topic), func(sub Subscription[S]) bool {
+		return !sub.NoLocal || sub.Subscriber != publisher
+	})
+}
+
+// Count returns the number of subscriptions.
+func (s *Subscriptions[S]) Count() int {
+	return s.tree.Count()
+}
+
+// collectMatches groups the matched subscriptions kept by keep by subscriber,
+// in the order found.
+func collectMatches[S comparable](seq iter.Seq[Subscription[S]], keep func(Subscription[S]) bool) []SubscriberMatch[S] {
+	var list []SubscriberMatch[S]
+	index := make(map[S]int)
+	for sub := range seq {
+		if !keep(sub) {
+			continue
+		}
+		i, ok := index[sub.Subscriber]
+		if !ok {
+			i = len(list)
+			index[sub.Subscriber] = i
+			list = append(list, SubscriberMatch[S]{Subscriber: sub.Subscriber})
+		}
+		m := &list[i]
+		m.Subscriptions = append(m.Subscriptions, sub)
+		m.QoS = max(m.QoS, sub.QoS)
+	}
+	return list
+}
+
+// parseFilter normalizes the filter, keeping the prefix of a shared
+// subscription, and reports whether it is a shared subscription.
+func parseFilter(filter string) (string, bool, error) {
+	group, filter, err := ParseShared(filter)
+	if err != nil {
+		return "", false, err
+	}
+	if group == "" {
+		return filter, false, nil
+	}
+	return SharePrefix + "/" + group + "/" + filter, true, nil
+}
@@ -0,0 +1,109 @@
+package topic
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Subscriptions(t *testing.T) {
+	subs := NewSubscriptions[string]()
+
+	require.NoError(t, subs.Subscribe("sport/#", "a", SubscriptionOptions{QoS: 0, SubscriptionID: 1}))
+	require.NoError(t, subs.Subscribe("sport//tennis/+", "a", SubscriptionOptions{QoS: 2, SubscriptionID: 2}))
+	require.NoError(t, subs.Subscribe("sport/tennis/player1", "b", SubscriptionOptions{QoS: 1}))
+	require.NoError(t, subs.Subscribe("sport/tennis/player1", "b", SubscriptionOptions{QoS: 1, RetainAsPublished: true}))
+	require.Equal(t, ErrWildcards, subs.Subscribe("sport/#/player1", "c", SubscriptionOptions{}))
+	require.Equal(t, ErrQoS, subs.Subscribe("sport/#", "c", SubscriptionOptions{QoS: 3}))
+	assert.Equal(t, 3, subs.Count())
+
+	sub, ok := subs.Get("sport/tennis/player1", "b")
+	assert.True(t, ok)
+	assert.True(t, sub.RetainAsPublished)
+
+	matches := subs.Match("sport/tennis/player1")
+	require.Equal(t, 2, len(matches))
+	if matches[0].Subscriber != "a" {
+		matches[0], matches[1] = matches[1], matches[0]
+	}
+	assert.Equal(t, byte(2), matches[0].QoS)
+	assert.ElementsMatch(t, []uint32{1, 2}, matches[0].SubscriptionIDs())
+	assert.Equal(t, 2, len(matches[0].Subscriptions))
+	assert.Equal(t, []Subscription[string]{{
+		Subscriber:          "b",
+		Filter:              "sport/tennis/player1",
+		SubscriptionOptions: SubscriptionOptions{QoS: 1, RetainAsPublished: true},
+	}}, matches[1].Subscriptions)
+
+	subs.Unsubscribe("sport/tennis/+", "a")
+	matches = subs.Match("sport/tennis/player2")
+	require.Equal(t, 1, len(matches))
+	assert.Equal(t, byte(0), matches[0].QoS)
+
+	subs.UnsubscribeAll("a")
+	subs.UnsubscribeAll("b")
+	assert.Equal(t, 0, subs.Count())
+	assert.Nil(t, subs.Match("sport/tennis/player1"))
+}
+
+func Test_SubscriptionsConcurrentGet(t *testing.T) {
+	subs := NewSubscriptions[string]()
+	require.NoError(t, subs.Subscribe("sport/#", "a", SubscriptionOptions{}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			_ = subs.Subscribe("sport/#", "a", SubscriptionOptions{QoS: byte(i % 3)})
+		}
+	}()
+	for range 100 {
+		_, ok := subs.Get("sport/#", "a")
+		assert.True(t, ok)
+	}
+	wg.Wait()
+}
+
+func Test_SubscriptionsNoLocal(t *testing.T) {
+	subs := NewSubscriptions[string]()
+
+	require.NoError(t, subs.Subscribe("chat/#", "a", SubscriptionOptions{QoS: 1, NoLocal: true}))
+	require.NoError(t, subs.Subscribe("chat/+", "a", SubscriptionOptions{QoS: 0}))
+	require.NoError(t, subs.Subscribe("chat/+", "b", SubscriptionOptions{QoS: 1, NoLocal: true}))
+	require.Equal(t, ErrSharedSubscription, subs.Subscribe("$share/g/chat/+", "c", SubscriptionOptions{NoLocal: true}))
+
+	matches := subs.MatchFrom("chat/room", "a")
+	require.Equal(t, 2, len(matches))
+	for _, m := range matches {
+		if m.Subscriber == "a" {
+			assert.Equal(t, byte(0), m.QoS)
+			assert.Equal(t, "chat/+", m.Subscriptions[0].Filter)
+		}
+	}
+	assert.Equal(t, 2, len(subs.Match("chat/room")))
+}
+
+func Test_SubscriptionsShared(t *testing.T) {
+	subs := NewSubscriptions(WithCopyOnWrite[Subscription[string]]())
+
+	require.NoError(t, subs.Subscribe("$share/g/jobs//+", "a", SubscriptionOptions{QoS: 1}))
+	require.NoError(t, subs.Subscribe("$share/g/jobs/+", "b", SubscriptionOptions{QoS: 2}))
+
+	_, ok := subs.Get("$share/g/jobs/+", "a")
+	assert.True(t, ok)
+
+	counts := map[string]int{}
+	for range 4 {
+		matches := subs.Match("jobs/1")
+		require.Equal(t, 1, len(matches))
+		assert.Equal(t, "$share/g/jobs/+", matches[0].Subscriptions[0].Filter)
+		counts[matches[0].Subscriber]++
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, counts)
+
+	subs.Unsubscribe("$share/g/jobs/+", "a")
+	assert.Equal(t, 1, subs.Count())
+}
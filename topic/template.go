@@ -0,0 +1,181 @@
+package topic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrTemplate is returned by ParseTemplate if a template is invalid, and by
+// the methods of Template if a parameter is missing.
+var ErrTemplate = errors.New("invalid topic template")
+
+// paramEscaper escapes the characters of a parameter which are not allowed in
+// a topic level, and the escape character itself.
+var paramEscaper = strings.NewReplacer("%", "%25", "/", "%2F", "+", "%2B", "#", "%23")
+
+// paramUnescapes are the escapes of paramEscaper, by their hex digits.
+var paramUnescapes = map[string]byte{"25": '%', "2F": '/', "2B": '+', "23": '#'}
+
+// A Template is a topic with named parameters, e.g.
+// "device/{tenant}/{id}/telemetry", where each parameter is a whole level.
+// It renders topics from parameters, extracts the parameters from topics,
+// and produces the filter matching the topics. The parameter values are
+// escaped, so that they may contain the separator and wildcards.
+type Template struct {
+	template string
+	levels   []string // literal levels, or parameter names if params[i] is set
+	params   []bool
+}
+
+// ParseTemplate parses and normalizes the template with the Parse rules. A
+// template may not contain wildcards, and every parameter must be a whole
+// level with a unique name.
+func ParseTemplate(template string) (*Template, error) {
+	template, err := Parse(template, false)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{template: template}
+	for level := range strings.SplitSeq(template, "/") {
+		name, ok := strings.CutPrefix(level, "{")
+		if ok {
+			name, ok = strings.CutSuffix(name, "}")
+		}
+		switch {
+		case ok && (name == "" || strings.ContainsAny(name, "{}")):
+			return nil, fmt.Errorf("%w: invalid parameter %q", ErrTemplate, level)
+		case ok && slices.Contains(t.Params(), name):
+			return nil, fmt.Errorf("%w: duplicate parameter %q", ErrTemplate, name)
+		case !ok && strings.ContainsAny(level, "{}"):
+			return nil, fmt.Errorf("%w: parameter %q is not a whole level", ErrTemplate, level)
+		case ok:
+			t.levels = append(t.levels, name)
+		default:
+			t.levels = append(t.levels, level)
+		}
+		t.params = append(t.params, ok)
+	}
+	return t, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics if the template is
+// invalid.
+func MustParseTemplate(template string) *Template {
+	t, err := ParseTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the normalized template.
+func (t *Template) String() string { return t.template }
+
+// Params returns the names of the parameters, in order.
+func (t *Template) Params() []string {
+	var names []string
+	for i, level := range t.levels {
+		if t.params[i] {
+			names = append(names, level)
+		}
+	}
+	return names
+}
+
+// Render returns the topic of the template with the parameters replaced by
+// their escaped values. Returns ErrTemplate if a parameter is missing or
+// empty.
+func (t *Template) Render(params map[string]string) (string, error) {
+	return t.render(params, false)
+}
+
+// Filter returns the filter of the template with the supplied parameters
+// replaced by their escaped values, and the missing ones by the single level
+// wildcard "+". Filter(nil) matches all topics of the template.
+func (t *Template) Filter(params map[string]string) (string, error) {
+	return t.render(params, true)
+}
+
+func (t *Template) render(params map[string]string, wildcards bool) (string, error) {
+	var b strings.Builder
+	for i, level := range t.levels {
+		if i > 0 {
+			b.WriteByte('/')
+		}
+		if !t.params[i] {
+			b.WriteString(level)
+			continue
+		}
+
+		value, ok := params[level]
+		switch {
+		case !ok && wildcards:
+			b.WriteString("+")
+		case value == "":
+			return "", fmt.Errorf("%w: missing parameter %q", ErrTemplate, level)
+		default:
+			b.WriteString(paramEscaper.Replace(value))
+		}
+	}
+	return b.String(), nil
+}
+
+// Extract returns the unescaped parameters of a topic rendered from the
+// template, and a bool indicating whether the topic matches the template.
+// It is the inverse of Render: a level with an escape which Render does not
+// produce does not match.
+func (t *Template) Extract(topic string) (map[string]string, bool) {
+	params := make(map[string]string)
+	i := 0
+	for level := range strings.SplitSeq(topic, "/") {
+		if i == len(t.levels) {
+			return nil, false
+		}
+		if !t.params[i] {
+			if level != t.levels[i] {
+				return nil, false
+			}
+			i++
+			continue
+		}
+
+		value, ok := unescapeParam(level)
+		if !ok || value == "" || ContainsWildcards(level) {
+			return nil, false
+		}
+		params[t.levels[i]] = value
+		i++
+	}
+	if i != len(t.levels) {
+		return nil, false
+	}
+	return params, true
+}
+
+// unescapeParam reverses paramEscaper. Returns false if the level contains
+// any other escape.
+func unescapeParam(level string) (string, bool) {
+	if !strings.Contains(level, "%") {
+		return level, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(level); i++ {
+		if level[i] != '%' {
+			b.WriteByte(level[i])
+			continue
+		}
+		if i+3 > len(level) {
+			return "", false
+		}
+		c, ok := paramUnescapes[level[i+1:i+3]]
+		if !ok {
+			return "", false
+		}
+		b.WriteByte(c)
+		i += 2
+	}
+	return b.String(), true
+}
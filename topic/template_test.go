@@ -0,0 +1,76 @@
+package topic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Template(t *testing.T) {
+	tpl, err := ParseTemplate("device//{tenant}/{id}/telemetry/")
+	require.NoError(t, err)
+	require.Equal(t, "device/{tenant}/{id}/telemetry", tpl.String())
+	require.Equal(t, []string{"tenant", "id"}, tpl.Params())
+
+	topic, err := tpl.Render(map[string]string{"tenant": "acme", "id": "a/b+c#d%e"})
+	require.NoError(t, err)
+	require.Equal(t, "device/acme/a%2Fb%2Bc%23d%25e/telemetry", topic)
+
+	params, ok := tpl.Extract(topic)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"tenant": "acme", "id": "a/b+c#d%e"}, params)
+
+	_, err = tpl.Render(map[string]string{"tenant": "acme"})
+	require.ErrorIs(t, err, ErrTemplate)
+	_, err = tpl.Render(map[string]string{"tenant": "acme", "id": ""})
+	require.ErrorIs(t, err, ErrTemplate)
+
+	filter, err := tpl.Filter(nil)
+	require.NoError(t, err)
+	require.Equal(t, "device/+/+/telemetry", filter)
+	filter, err = tpl.Filter(map[string]string{"tenant": "acme"})
+	require.NoError(t, err)
+	require.Equal(t, "device/acme/+/telemetry", filter)
+
+	tree := NewStandardTree()
+	tree.Add(filter, 1)
+	require.Equal(t, []any{1}, tree.Match(topic))
+}
+
+func Test_TemplateExtractMismatch(t *testing.T) {
+	tpl := MustParseTemplate("device/{tenant}/{id}/telemetry")
+
+	tests := []string{
+		"device/acme/1",
+		"device/acme/1/telemetry/extra",
+		"device/acme/1/status",
+		"device/+/1/telemetry",
+		"device//1/telemetry",
+		"device/acme/%zz/telemetry",
+		"device/acme/%41/telemetry",
+		"device/acme/%2f/telemetry",
+		"device/acme/1%2/telemetry",
+	}
+	for _, topic := range tests {
+		_, ok := tpl.Extract(topic)
+		require.False(t, ok, topic)
+	}
+}
+
+func Test_ParseTemplateError(t *testing.T) {
+	tests := map[string]error{
+		"":                   ErrZeroLength,
+		"device/+/{id}":      ErrWildcards,
+		"device/{}/status":   ErrTemplate,
+		"device/{a}/{a}":     ErrTemplate,
+		"device/id-{id}":     ErrTemplate,
+		"device/{{id}}":      ErrTemplate,
+		"device/{id/status}": ErrTemplate,
+	}
+	for template, want := range tests {
+		_, err := ParseTemplate(template)
+		require.ErrorIs(t, err, want, template)
+	}
+
+	require.Panics(t, func() { MustParseTemplate("device/{}") })
+}
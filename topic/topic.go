@@ -12,6 +12,10 @@ var ErrZeroLength = errors.New("zero length topic")
 // ErrWildcards is returned by Parse if a topic contains invalid wildcards.
 var ErrWildcards = errors.New("invalid use of wildcards")
 
+// ErrSharedSubscription is returned by ParseShared if a shared subscription
+// has no or an invalid share name.
+var ErrSharedSubscription = errors.New("invalid shared subscription")
+
 // Parse removes duplicate and trailing slashes from the supplied
 // string and returns the normalized topic.
 func Parse(topic string, allowWildcards bool) (string, error) {
@@ -62,6 +66,32 @@ func Parse(topic string, allowWildcards bool) (string, error) {
 	return topic, nil
 }
 
+// ParseShared splits a MQTT 5 shared subscription "$share/<group>/<filter>"
+// into its share name and the filter normalized by Parse. For other topics,
+// the group is empty and the filter is the topic normalized by Parse.
+func ParseShared(topic string) (group, filter string, err error) {
+	rest, ok := strings.CutPrefix(topic, SharePrefix+"/")
+	if !ok {
+		if topic == SharePrefix {
+			return "", "", ErrSharedSubscription
+		}
+		filter, err = Parse(topic, true)
+		return "", filter, err
+	}
+
+	// check share name
+	group, filter, _ = strings.Cut(rest, "/")
+	if group == "" || ContainsWildcards(group) {
+		return "", "", ErrSharedSubscription
+	}
+
+	filter, err = Parse(filter, true)
+	if err != nil {
+		return "", "", err
+	}
+	return group, filter, nil
+}
+
 // ContainsWildcards tests if the supplied topic contains wildcards. The topic
 // is expected to be tested and normalized using Parse beforehand.
 func ContainsWildcards(topic string) bool {
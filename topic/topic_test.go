@@ -93,6 +93,29 @@ func Test_ParseAllowWildcards(t *testing.T) {
 	}
 }
 
+func Test_ParseShared(t *testing.T) {
+	group, filter, err := ParseShared("$share/workers//jobs/+/")
+	require.NoError(t, err)
+	require.Equal(t, "workers", group)
+	require.Equal(t, "/jobs/+", filter)
+
+	group, filter, err = ParseShared("jobs//#")
+	require.NoError(t, err)
+	require.Equal(t, "", group)
+	require.Equal(t, "jobs/#", filter)
+
+	for _, topic := range []string{"$share", "$share/", "$share//jobs", "$share/a+b/jobs", "$share/#/jobs"} {
+		_, _, err = ParseShared(topic)
+		require.Equal(t, ErrSharedSubscription, err, topic)
+	}
+
+	_, _, err = ParseShared("$share/workers")
+	require.Equal(t, ErrZeroLength, err)
+
+	_, _, err = ParseShared("$share/workers/jobs/#/all")
+	require.Equal(t, ErrWildcards, err)
+}
+
 func Test_ContainsWildcards(t *testing.T) {
 	require.True(t, ContainsWildcards("topic/+"))
 	require.True(t, ContainsWildcards("topic/#"))
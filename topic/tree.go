@@ -2,45 +2,144 @@ package topic
 
 import (
 	"fmt"
+	"iter"
+	"maps"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 const topicEnd = "\x00"
 
-type node struct {
-	children map[string]*node
-	values   []any
+// SharePrefix is the first level of a MQTT 5 shared subscription
+// "$share/<group>/<filter>".
+const SharePrefix = "$share"
+
+type node[V any] struct {
+	children map[string]*node[V]
+	values   []V
+	shared   map[string]*shareGroup[V] // shared subscriptions of the filter by group
+}
+
+// shareGroup are the subscribers of a shared subscription.
+type shareGroup[V any] struct {
+	values []V
+	next   atomic.Uint64 // round-robin counter
 }
 
-func newNode() *node {
-	return &node{
-		children: make(map[string]*node),
+// SharePicker picks the subscriber of a shared subscription which receives
+// a message published to topic, from the non-empty subscribers of the group.
+type SharePicker[V any] func(group, topic string, subscribers []V) V
+
+func newNode[V any]() *node[V] {
+	return &node[V]{
+		children: make(map[string]*node[V]),
 	}
 }
 
-func (n *node) removeValue(value any) {
+// clone returns a copy of the node which can be modified without changing n.
+// The children are shared.
+func (n *node[V]) clone() *node[V] {
+	c := &node[V]{
+		children: maps.Clone(n.children),
+		values:   slices.Clone(n.values),
+	}
+	if n.shared != nil {
+		c.shared = make(map[string]*shareGroup[V], len(n.shared))
+		for group, g := range n.shared {
+			cg := &shareGroup[V]{values: slices.Clone(g.values)}
+			cg.next.Store(g.next.Load())
+			c.shared[group] = cg
+		}
+	}
+	return c
+}
+
+func (n *node[V]) removeValue(value V, equal func(a, b V) bool) {
 	for i, v := range n.values {
-		if v == value {
+		if equal(v, value) {
 			// remove without preserving order
+			var zero V
 			n.values[i] = n.values[len(n.values)-1]
-			n.values[len(n.values)-1] = nil
+			n.values[len(n.values)-1] = zero
 			n.values = n.values[:len(n.values)-1]
 			break
 		}
 	}
 }
 
-func (n *node) clearValues() {
-	n.values = []any{}
+func (n *node[V]) clearValues() {
+	n.values = []V{}
+}
+
+// groupValues returns the values of a shared subscription group, or the
+// values of the node if group is empty.
+func (n *node[V]) groupValues(group string) []V {
+	if group == "" {
+		return n.values
+	}
+	if g, ok := n.shared[group]; ok {
+		return g.values
+	}
+	return nil
+}
+
+// setGroupValues sets the values of a shared subscription group, or the
+// values of the node if group is empty. An empty group is removed.
+func (n *node[V]) setGroupValues(group string, values []V) {
+	if group == "" {
+		n.values = values
+		return
+	}
+	if len(values) == 0 {
+		delete(n.shared, group)
+		return
+	}
+	g, ok := n.shared[group]
+	if !ok {
+		if n.shared == nil {
+			n.shared = make(map[string]*shareGroup[V])
+		}
+		g = &shareGroup[V]{}
+		n.shared[group] = g
+	}
+	g.values = values
+}
+
+// removeShared removes the value from all shared subscription groups.
+func (n *node[V]) removeShared(value V, equal func(a, b V) bool) {
+	for group, g := range n.shared {
+		if i := indexOf(g.values, value, equal); i >= 0 {
+			n.setGroupValues(group, slices.Delete(g.values, i, i+1))
+		}
+	}
+}
+
+// hasValues reports whether the node has values or shared subscriptions.
+func (n *node[V]) hasValues() bool {
+	return len(n.values) > 0 || len(n.shared) > 0
 }
 
-func (n *node) string(level int) string {
+// empty reports whether the node can be removed from the tree.
+func (n *node[V]) empty() bool {
+	return !n.hasValues() && len(n.children) == 0
+}
+
+// valueCount returns the number of values, including the shared subscriptions.
+func (n *node[V]) valueCount() int {
+	total := len(n.values)
+	for _, g := range n.shared {
+		total += len(g.values)
+	}
+	return total
+}
+
+func (n *node[V]) string(level int) string {
 	// print node length unless on root level
 	str := ""
 	if level != 0 {
-		str = fmt.Sprintf("%d", len(n.values))
+		str = fmt.Sprintf("%d", n.valueCount())
 	}
 
 	// ident and append children
@@ -51,112 +150,276 @@ func (n *node) string(level int) string {
 	return str
 }
 
-// A Tree implements a thread-safe topic tree.
-type Tree struct {
+// TreeOption configures a TreeOf.
+type TreeOption[V any] func(*TreeOf[V])
+
+// WithEqual sets the function reporting whether two values are the same
+// value. By default, values are compared with ==, which panics if the dynamic
+// types of the values are not comparable.
+func WithEqual[V any](equal func(a, b V) bool) TreeOption[V] {
+	return func(t *TreeOf[V]) {
+		t.equal = equal
+		t.key = nil
+	}
+}
+
+// WithCopyOnWrite makes the tree read-optimized: the tree is an immutable
+// snapshot, which the writes copy along the modified path and swap
+// atomically. The reads take no lock and scale across cores, while the writes
+// are serialized and slower. Use it if the tree changes rarely compared to
+// the matches, e.g. the subscriptions of a message router.
+func WithCopyOnWrite[V any]() TreeOption[V] {
+	return func(t *TreeOf[V]) {
+		t.cow = true
+	}
+}
+
+// WithKey identifies the values by the key returned by key, e.g. the client id
+// of a subscription. Two values with the same key are the same value, and
+// the results are deduplicated in linear time.
+func WithKey[V any, K comparable](key func(V) K) TreeOption[V] {
+	return func(t *TreeOf[V]) {
+		t.key = func(v V) any { return key(v) }
+		t.equal = func(a, b V) bool { return key(a) == key(b) }
+	}
+}
+
+// A TreeOf implements a thread-safe topic tree of values of type V.
+//
+// The tree supports MQTT 5 shared subscriptions: a value added to
+// "$share/<group>/<filter>" is a subscriber of the group for the filter, and
+// Match returns one subscriber of each group whose filter matches, picked
+// round-robin or by the SharePicker. As required by MQTT, wildcards at the
+// first level do not match topics starting with "$", such as "$SYS/uptime".
+type TreeOf[V any] struct {
 	separator    string
 	wildcardOne  string
 	wildcardSome string
-	root         *node
+	root         *node[V]
+	picker       atomic.Pointer[SharePicker[V]]
+	equal        func(a, b V) bool
+	key          func(V) any
 	mutex        sync.RWMutex
+
+	cow      bool                    // copy-on-write mode, see WithCopyOnWrite
+	snapshot atomic.Pointer[node[V]] // root read by the readers in copy-on-write mode
 }
 
+// A Tree implements a thread-safe topic tree of values of any type.
+type Tree = TreeOf[any]
+
 // NewTree returns a new Tree using the specified separator and wildcards.
-func NewTree(separator, wildcardOne, wildcardSome string) *Tree {
-	return &Tree{
+func NewTree(separator, wildcardOne, wildcardSome string, opts ...TreeOption[any]) *Tree {
+	return NewTreeOf(separator, wildcardOne, wildcardSome, opts...)
+}
+
+// NewStandardTree returns a new Tree using the standard MQTT separator and
+// wildcards.
+func NewStandardTree(opts ...TreeOption[any]) *Tree {
+	return NewStandardTreeOf(opts...)
+}
+
+// NewTreeOf returns a new TreeOf using the specified separator and wildcards.
+func NewTreeOf[V any](separator, wildcardOne, wildcardSome string, opts ...TreeOption[V]) *TreeOf[V] {
+	t := &TreeOf[V]{
 		separator:    separator,
 		wildcardOne:  wildcardOne,
 		wildcardSome: wildcardSome,
-		root:         newNode(),
+		root:         newNode[V](),
+		equal:        func(a, b V) bool { return any(a) == any(b) },
 	}
+	for _, f := range opts {
+		f(t)
+	}
+	t.snapshot.Store(t.root)
+	return t
 }
 
-// NewStandardTree returns a new Tree using the standard MQTT separator and
+// NewStandardTreeOf returns a new TreeOf using the standard MQTT separator and
 // wildcards.
-func NewStandardTree() *Tree {
-	return NewTree("/", "+", "#")
+func NewStandardTreeOf[V any](opts ...TreeOption[V]) *TreeOf[V] {
+	return NewTreeOf("/", "+", "#", opts...)
 }
 
-// Add registers the value for the supplied topic. This function will
-// automatically grow the tree. If value already exists for the given topic it
-// will not be added again.
-func (t *Tree) Add(topic string, value any) {
+// SetSharePicker sets the function picking the subscriber of a shared
+// subscription in Match. If nil, the subscribers are picked round-robin.
+func (t *TreeOf[V]) SetSharePicker(picker SharePicker[V]) {
+	if picker == nil {
+		t.picker.Store(nil)
+		return
+	}
+	t.picker.Store(&picker)
+}
+
+// rlock locks the tree for reading and returns its root. In copy-on-write
+// mode, it takes no lock and returns the current snapshot.
+func (t *TreeOf[V]) rlock() *node[V] {
+	if t.cow {
+		return t.snapshot.Load()
+	}
+	t.mutex.RLock()
+	return t.root
+}
+
+func (t *TreeOf[V]) runlock() {
+	if !t.cow {
+		t.mutex.RUnlock()
+	}
+}
+
+// update calls fn with the root to modify under the write lock. In
+// copy-on-write mode, fn modifies a copy, which is then swapped in.
+func (t *TreeOf[V]) update(fn func(root *node[V])) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
+	root := t.mutable(t.root)
+	fn(root)
+	t.root = root
+	if t.cow {
+		t.snapshot.Store(root)
+	}
+}
+
+// mutable returns the node to modify: the node itself, or a copy in
+// copy-on-write mode.
+func (t *TreeOf[V]) mutable(n *node[V]) *node[V] {
+	if t.cow {
+		return n.clone()
+	}
+	return n
+}
+
+// mutableChild returns the child to modify for the segment, and stores it in
+// the modifiable node. If create is set, a missing child is created.
+func (t *TreeOf[V]) mutableChild(node *node[V], segment string, create bool) *node[V] {
+	child, ok := node.children[segment]
+	switch {
+	case ok:
+		child = t.mutable(child)
+	case create:
+		child = newNode[V]()
+	default:
+		return nil
+	}
+	node.children[segment] = child
+	return child
+}
+
+// shared splits a shared subscription "$share/<group>/<filter>" into its group
+// and filter. Returns an empty group for other topics.
+func (t *TreeOf[V]) shared(topic string) (group, filter string) {
+	rest, ok := strings.CutPrefix(topic, SharePrefix+t.separator)
+	if !ok {
+		return "", topic
+	}
+	group, filter, ok = strings.Cut(rest, t.separator)
+	if !ok || group == "" || filter == "" {
+		return "", topic
+	}
+	return group, filter
+}
+
+// Add registers the value for the supplied topic. This function will
+// automatically grow the tree. If value already exists for the given topic it
+// will not be added again.
+func (t *TreeOf[V]) Add(topic string, value V) {
 	// add value
-	t.add(value, topic, t.root)
+	group, topic := t.shared(topic)
+	t.update(func(root *node[V]) {
+		t.add(group, value, false, topic, root)
+	})
+}
+
+// put behaves similar to Add, but replaces the value if it already exists for
+// the given topic.
+func (t *TreeOf[V]) put(topic string, value V) {
+	group, topic := t.shared(topic)
+	t.update(func(root *node[V]) {
+		t.add(group, value, true, topic, root)
+	})
 }
 
-func (t *Tree) add(value any, topic string, node *node) {
+func (t *TreeOf[V]) add(group string, value V, replace bool, topic string, node *node[V]) {
 	// add value to leaf
 	if topic == topicEnd {
+		values := node.groupValues(group)
+
 		// check if duplicate
-		if slices.Contains(node.values, value) {
+		if i := indexOf(values, value, t.equal); i >= 0 {
+			if replace {
+				values[i] = value
+			}
 			return
 		}
 
 		// add value
-		node.values = append(node.values, value)
+		node.setGroupValues(group, append(values, value))
 		return
 	}
 
-	// get segment
-	segment := topicSegment(topic, t.separator)
-
 	// get child
-	child, ok := node.children[segment]
-	if !ok {
-		child = newNode()
-		node.children[segment] = child
-	}
+	child := t.mutableChild(node, topicSegment(topic, t.separator), true)
 
 	// descend
-	t.add(value, topicShorten(topic, t.separator), child)
+	t.add(group, value, replace, topicShorten(topic, t.separator), child)
 }
 
 // Set sets the supplied value as the only value for the supplied topic. This
 // function will automatically grow the tree.
-func (t *Tree) Set(topic string, value any) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+func (t *TreeOf[V]) Set(topic string, value V) {
 	// set value
-	t.set(value, topic, t.root)
+	group, topic := t.shared(topic)
+	t.update(func(root *node[V]) {
+		t.set(group, value, topic, root)
+	})
 }
 
-func (t *Tree) set(value any, topic string, node *node) {
+func (t *TreeOf[V]) set(group string, value V, topic string, node *node[V]) {
 	// set value on leaf
 	if topic == topicEnd {
-		node.values = []any{value}
+		node.setGroupValues(group, []V{value})
 		return
 	}
 
-	// get segment
-	segment := topicSegment(topic, t.separator)
-
 	// get child
-	child, ok := node.children[segment]
-	if !ok {
-		child = newNode()
-		node.children[segment] = child
-	}
+	child := t.mutableChild(node, topicSegment(topic, t.separator), true)
 
 	// descend
-	t.set(value, topicShorten(topic, t.separator), child)
+	t.set(group, value, topicShorten(topic, t.separator), child)
 }
 
 // Get gets the values from the topic that exactly matches the supplied topics.
-func (t *Tree) Get(topic string) []any {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+func (t *TreeOf[V]) Get(topic string) []V {
+	root := t.rlock()
+	defer t.runlock()
 
 	// get values
-	return t.get(topic, t.root)
+	group, topic := t.shared(topic)
+	return t.get(group, topic, root)
 }
 
-func (t *Tree) get(topic string, node *node) []any {
+// find returns the first value of the topic for which match returns true, and
+// a bool indicating whether it was found. In contrast to Get, the values are
+// read under the lock, so that a concurrent replace can not modify them.
+func (t *TreeOf[V]) find(topic string, match func(V) bool) (V, bool) {
+	root := t.rlock()
+	defer t.runlock()
+
+	group, topic := t.shared(topic)
+	for _, v := range t.get(group, topic, root) {
+		if match(v) {
+			return v, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func (t *TreeOf[V]) get(group, topic string, node *node[V]) []V {
 	// set value on leaf
 	if topic == topicEnd {
-		return node.values
+		return node.groupValues(group)
 	}
 
 	// get segment
@@ -169,136 +432,199 @@ func (t *Tree) get(topic string, node *node) []any {
 	}
 
 	// descend
-	return t.get(topicShorten(topic, t.separator), child)
+	return t.get(group, topicShorten(topic, t.separator), child)
 }
 
 // Remove un-registers the value from the supplied topic. This function will
 // automatically shrink the tree.
-func (t *Tree) Remove(topic string, value any) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
+func (t *TreeOf[V]) Remove(topic string, value V) {
 	// remove value
-	t.remove(value, topic, t.root)
+	group, topic := t.shared(topic)
+	t.update(func(root *node[V]) {
+		t.remove(group, &value, topic, root)
+	})
 }
 
 // Empty will unregister all values from the supplied topic. This function will
 // automatically shrink the tree.
-func (t *Tree) Empty(topic string) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
+func (t *TreeOf[V]) Empty(topic string) {
 	// empty values
-	t.remove(nil, topic, t.root)
+	group, topic := t.shared(topic)
+	t.update(func(root *node[V]) {
+		t.remove(group, nil, topic, root)
+	})
 }
 
-func (t *Tree) remove(value any, topic string, node *node) bool {
+// remove removes the value from the topic, or all values if value is nil.
+func (t *TreeOf[V]) remove(group string, value *V, topic string, node *node[V]) bool {
 	// clear or remove value from leaf node
 	if topic == topicEnd {
-		if value == nil {
+		switch {
+		case group != "" && value == nil:
+			node.setGroupValues(group, nil)
+		case group != "":
+			values := node.groupValues(group)
+			if i := indexOf(values, *value, t.equal); i >= 0 {
+				node.setGroupValues(group, slices.Delete(values, i, i+1))
+			}
+		case value == nil:
 			node.clearValues()
-		} else {
-			node.removeValue(value)
+		default:
+			node.removeValue(*value, t.equal)
 		}
 
-		return len(node.values) == 0 && len(node.children) == 0
+		return node.empty()
 	}
 
 	// get segment
 	segment := topicSegment(topic, t.separator)
 
 	// get child
-	child, ok := node.children[segment]
-	if !ok {
+	child := t.mutableChild(node, segment, false)
+	if child == nil {
 		return false
 	}
 
 	// descend and remove node if empty
-	if t.remove(value, topicShorten(topic, t.separator), child) {
+	if t.remove(group, value, topicShorten(topic, t.separator), child) {
 		delete(node.children, segment)
 	}
 
-	return len(node.values) == 0 && len(node.children) == 0
+	return node.empty()
 }
 
 // Clear will unregister the supplied value from all topics. This function will
 // automatically shrink the tree.
-func (t *Tree) Clear(value any) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
+func (t *TreeOf[V]) Clear(value V) {
 	// clear value
-	t.clear(value, t.root)
+	t.update(func(root *node[V]) {
+		t.clear(value, root)
+	})
 }
 
-func (t *Tree) clear(value any, node *node) bool {
+func (t *TreeOf[V]) clear(value V, node *node[V]) bool {
 	// remove value
-	node.removeValue(value)
+	node.removeValue(value, t.equal)
+	node.removeShared(value, t.equal)
 
 	// remove value from all children and remove empty nodes
-	for segment, child := range node.children {
-		if t.clear(value, child) {
+	for segment := range node.children {
+		if t.clear(value, t.mutableChild(node, segment, false)) {
 			delete(node.children, segment)
 		}
 	}
 
-	return len(node.values) == 0 && len(node.children) == 0
+	return node.empty()
 }
 
 // Match will return a set of values from topics that match the supplied topic.
-// The result set will be cleared from duplicate values.
+// The result set will be cleared from duplicate values. Of every shared
+// subscription that matches, only one subscriber is returned.
 //
 // Note: In contrast to Search, Match does not respect wildcards in the query but
 // in the stored tree.
-func (t *Tree) Match(topic string) []any {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) Match(topic string) []V {
+	root := t.rlock()
+	defer t.runlock()
 
 	// match values
-	var list []any
-	t.match(topic, t.root, func(values []any) bool {
-		list = append(list, values...)
+	var list []V
+	t.match(topic, root, true, t.matchValues(topic, func(value V) bool {
+		list = append(list, value)
 		return true
-	})
+	}))
 
 	return t.clean(list)
 }
 
 // MatchFirst behaves similar to Match but only returns the first found value.
-func (t *Tree) MatchFirst(topic string) any {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) MatchFirst(topic string) V {
+	root := t.rlock()
+	defer t.runlock()
 
 	// match values
-	var value any
-	t.match(topic, t.root, func(values []any) bool {
-		value = values[0]
+	var value V
+	t.match(topic, root, true, t.matchValues(topic, func(v V) bool {
+		value = v
 		return false
-	})
+	}))
 
 	return value
 }
 
-func (t *Tree) match(topic string, node *node, fn func([]any) bool) {
+// MatchSeq returns an iterator over the values from topics that match the
+// supplied topic, like Match. The values are not deduplicated: a value stored
+// for several matching topics is yielded once per topic. The tree is read
+// locked while iterating, so it must not be modified by the loop body, unless
+// it is in copy-on-write mode, which iterates over a snapshot.
+func (t *TreeOf[V]) MatchSeq(topic string) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		root := t.rlock()
+		defer t.runlock()
+
+		t.match(topic, root, true, t.matchValues(topic, yield))
+	}
+}
+
+// matchValues returns a function calling fn with the values of a matched
+// node, and with the picked subscriber of each of its shared subscriptions.
+func (t *TreeOf[V]) matchValues(topic string, fn func(V) bool) func(*node[V]) bool {
+	return func(n *node[V]) bool {
+		for _, v := range n.values {
+			if !fn(v) {
+				return false
+			}
+		}
+		for group, g := range n.shared {
+			if !fn(t.pick(group, topic, g)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// pick picks the subscriber of a shared subscription.
+func (t *TreeOf[V]) pick(group, topic string, g *shareGroup[V]) V {
+	if picker := t.picker.Load(); picker != nil {
+		return (*picker)(group, topic, g.values)
+	}
+	return g.values[(g.next.Add(1)-1)%uint64(len(g.values))]
+}
+
+// isSystem reports whether the level is a "$" level, e.g. "$SYS", which is not
+// matched by wildcards at the first level.
+func isSystem(segment string) bool {
+	return strings.HasPrefix(segment, "$")
+}
+
+// match calls fn with the nodes matching the topic, until fn returns false.
+// Returns false if stopped.
+func (t *TreeOf[V]) match(topic string, node *node[V], top bool, fn func(*node[V]) bool) bool {
+	// wildcards at the first level do not match "$" topics
+	wildcards := !top || !isSystem(topic)
+
 	// add all values to the result set that match multiple levels
-	if child, ok := node.children[t.wildcardSome]; ok && len(child.values) > 0 {
-		if !fn(child.values) {
-			return
+	if child, ok := node.children[t.wildcardSome]; ok && wildcards && child.hasValues() {
+		if !fn(child) {
+			return false
 		}
 	}
 
 	// when finished add all values to the result set
 	if topic == topicEnd {
-		if len(node.values) > 0 {
-			fn(node.values)
+		if node.hasValues() {
+			return fn(node)
 		}
 
-		return
+		return true
 	}
 
 	// advance children that match a single level
-	if child, ok := node.children[t.wildcardOne]; ok {
-		t.match(topicShorten(topic, t.separator), child, fn)
+	if child, ok := node.children[t.wildcardOne]; ok && wildcards {
+		if !t.match(topicShorten(topic, t.separator), child, false, fn) {
+			return false
+		}
 	}
 
 	// get segment
@@ -307,53 +633,90 @@ func (t *Tree) match(topic string, node *node, fn func([]any) bool) {
 	// match segments and get children
 	if segment != t.wildcardOne && segment != t.wildcardSome {
 		if child, ok := node.children[segment]; ok {
-			t.match(topicShorten(topic, t.separator), child, fn)
+			return t.match(topicShorten(topic, t.separator), child, false, fn)
 		}
 	}
+
+	return true
 }
 
 // Search will return a set of values from topics that match the supplied topic.
-// The result set will be cleared from duplicate values.
+// The result set will be cleared from duplicate values. All subscribers of the
+// shared subscriptions found are returned.
 //
 // Note: In contrast to Match, Search respects wildcards in the query but not in
 // the stored tree.
-func (t *Tree) Search(topic string) []any {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) Search(topic string) []V {
+	root := t.rlock()
+	defer t.runlock()
 
 	// match values
-	var list []any
-	t.search(topic, t.root, func(values []any) bool {
-		list = append(list, values...)
+	var list []V
+	t.search(topic, root, true, searchValues(func(value V) bool {
+		list = append(list, value)
 		return true
-	})
+	}))
 
 	return t.clean(list)
 }
 
 // SearchFirst behaves similar to Search but only returns the first found value.
-func (t *Tree) SearchFirst(topic string) any {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) SearchFirst(topic string) V {
+	root := t.rlock()
+	defer t.runlock()
 
 	// match values
-	var value any
-	t.search(topic, t.root, func(values []any) bool {
-		value = values[0]
+	var value V
+	t.search(topic, root, true, searchValues(func(v V) bool {
+		value = v
 		return false
-	})
+	}))
 
 	return value
 }
 
-func (t *Tree) search(topic string, node *node, fn func([]any) bool) {
+// SearchSeq returns an iterator over the values from topics that match the
+// supplied topic, like Search. As with MatchSeq, the values are not
+// deduplicated, and the tree must not be modified by the loop body.
+func (t *TreeOf[V]) SearchSeq(topic string) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		root := t.rlock()
+		defer t.runlock()
+
+		t.search(topic, root, true, searchValues(yield))
+	}
+}
+
+// searchValues returns a function calling fn with the values of a found node,
+// and with the subscribers of each of its shared subscriptions.
+func searchValues[V any](fn func(V) bool) func(*node[V]) bool {
+	return func(n *node[V]) bool {
+		for _, v := range n.values {
+			if !fn(v) {
+				return false
+			}
+		}
+		for _, g := range n.shared {
+			for _, v := range g.values {
+				if !fn(v) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// search calls fn with the nodes found by the topic, until fn returns false.
+// Returns false if stopped.
+func (t *TreeOf[V]) search(topic string, node *node[V], top bool, fn func(*node[V]) bool) bool {
 	// when finished add all values to the result set
 	if topic == topicEnd {
-		if len(node.values) > 0 {
-			fn(node.values)
+		if node.hasValues() {
+			return fn(node)
 		}
 
-		return
+		return true
 	}
 
 	// get segment
@@ -361,44 +724,71 @@ func (t *Tree) search(topic string, node *node, fn func([]any) bool) {
 
 	// add all current and further values
 	if segment == t.wildcardSome {
-		if len(node.values) > 0 {
-			if !fn(node.values) {
-				return
+		if node.hasValues() {
+			if !fn(node) {
+				return false
 			}
 		}
 
-		for _, child := range node.children {
-			t.search(topic, child, fn)
+		for key, child := range node.children {
+			// wildcards at the first level do not match "$" topics
+			if top && isSystem(key) {
+				continue
+			}
+			if !t.search(topic, child, false, fn) {
+				return false
+			}
 		}
 	}
 
 	// add all current values and continue
 	if segment == t.wildcardOne {
-		if len(node.values) > 0 {
-			if !fn(node.values) {
-				return
+		if node.hasValues() {
+			if !fn(node) {
+				return false
 			}
 		}
 
-		for _, child := range node.children {
-			t.search(topicShorten(topic, t.separator), child, fn)
+		for key, child := range node.children {
+			if top && isSystem(key) {
+				continue
+			}
+			if !t.search(topicShorten(topic, t.separator), child, false, fn) {
+				return false
+			}
 		}
 	}
 
 	// match segments and get children
 	if segment != t.wildcardOne && segment != t.wildcardSome {
 		if child, ok := node.children[segment]; ok {
-			t.search(topicShorten(topic, t.separator), child, fn)
+			return t.search(topicShorten(topic, t.separator), child, false, fn)
 		}
 	}
+
+	return true
 }
 
 // clean will remove duplicates
-func (t *Tree) clean(values []any) []any {
+func (t *TreeOf[V]) clean(values []V) []V {
 	result := values[:0]
 
+	// deduplicate by key in linear time
+	if t.key != nil {
+		seen := make(map[any]struct{}, len(values))
+		for _, v := range values {
+			k := t.key(v)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			result = append(result, v)
+		}
+		return result
+	}
+
 	for _, v := range values {
-		if contains(result, v) {
+		if t.contains(result, v) {
 			continue
 		}
 
@@ -410,14 +800,14 @@ func (t *Tree) clean(values []any) []any {
 
 // Count will count all stored values in the tree. It will not filter out
 // duplicate values and thus might return a different result to `len(All())`.
-func (t *Tree) Count() int {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) Count() int {
+	root := t.rlock()
+	defer t.runlock()
 
-	return t.count(t.root)
+	return t.count(root)
 }
 
-func (t *Tree) count(node *node) int {
+func (t *TreeOf[V]) count(node *node[V]) int {
 	// prepare total
 	total := 0
 
@@ -427,46 +817,73 @@ func (t *Tree) count(node *node) int {
 	}
 
 	// add values to result
-	return total + len(node.values)
+	return total + node.valueCount()
 }
 
 // All will return all stored values in the tree.
-func (t *Tree) All() []any {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) All() []V {
+	root := t.rlock()
+	defer t.runlock()
 
-	return t.clean(t.all([]any{}, t.root))
+	var list []V
+	t.all(root, func(value V) bool {
+		list = append(list, value)
+		return true
+	})
+
+	return t.clean(list)
+}
+
+// AllSeq returns an iterator over all stored values in the tree. As with
+// MatchSeq, the values are not deduplicated, and the tree must not be
+// modified by the loop body.
+func (t *TreeOf[V]) AllSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		root := t.rlock()
+		defer t.runlock()
+
+		t.all(root, yield)
+	}
 }
 
-func (t *Tree) all(result []any, node *node) []any {
+// all calls fn with the values of the node and its children, until fn returns
+// false. Returns false if stopped.
+func (t *TreeOf[V]) all(node *node[V], fn func(V) bool) bool {
 	// add children to results
 	for _, child := range node.children {
-		result = t.all(result, child)
+		if !t.all(child, fn) {
+			return false
+		}
 	}
 
 	// add current node to results
-	return append(result, node.values...)
+	return searchValues(fn)(node)
 }
 
 // Reset will completely clear the tree.
-func (t *Tree) Reset() {
+func (t *TreeOf[V]) Reset() {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	t.root = newNode()
+	t.root = newNode[V]()
+	t.snapshot.Store(t.root)
 }
 
 // String will return a string representation of the tree structure. The number
 // following the nodes show the number of stored values at that level.
-func (t *Tree) String() string {
-	t.mutex.RLock()
-	defer t.mutex.RUnlock()
+func (t *TreeOf[V]) String() string {
+	root := t.rlock()
+	defer t.runlock()
+
+	return fmt.Sprintf("topic.Tree:%s", root.string(0))
+}
 
-	return fmt.Sprintf("topic.Tree:%s", t.root.string(0))
+func (t *TreeOf[V]) contains(list []V, value V) bool {
+	return indexOf(list, value, t.equal) >= 0
 }
 
-func contains(list []any, value any) bool {
-	return slices.Contains(list, value)
+func indexOf[V any](list []V, value V, equal func(a, b V) bool) int {
+	return slices.IndexFunc(list, func(v V) bool { return equal(v, value) })
 }
 
 func topicShorten(topic, separator string) string {
@@ -2,6 +2,10 @@ package topic
 
 import (
 	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -306,6 +310,139 @@ func Test_TreeString(t *testing.T) {
 	assert.Equal(t, "topic.Tree:\n| '' => 1\n|   'foo' => 1\n|     'bar' => 1", tree.String())
 }
 
+func Test_TreeShared(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("$share/g1/jobs/+", 1)
+	tree.Add("$share/g1/jobs/+", 2)
+	tree.Add("$share/g1/jobs/+", 3)
+	tree.Add("$share/g2/jobs/#", 4)
+	tree.Add("jobs/+", 5)
+
+	assert.Equal(t, []any{1, 2, 3}, tree.Get("$share/g1/jobs/+"))
+	assert.Equal(t, 5, tree.Count())
+	assert.ElementsMatch(t, []any{1, 2, 3, 4, 5}, tree.Search("jobs/#"))
+
+	counts := map[any]int{}
+	for range 6 {
+		result := tree.Match("jobs/a")
+		assert.Equal(t, 3, len(result))
+		assert.Contains(t, result, 4)
+		assert.Contains(t, result, 5)
+		for _, v := range result {
+			counts[v]++
+		}
+	}
+	assert.Equal(t, map[any]int{1: 2, 2: 2, 3: 2, 4: 6, 5: 6}, counts)
+
+	tree.Remove("$share/g1/jobs/+", 2)
+	tree.Clear(3)
+	assert.Equal(t, []any{1}, tree.Get("$share/g1/jobs/+"))
+
+	tree.Empty("$share/g1/jobs/+")
+	tree.Remove("$share/g2/jobs/#", 4)
+	tree.Remove("jobs/+", 5)
+	assert.Equal(t, 0, len(tree.root.children))
+}
+
+func Test_TreeSharePicker(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("$share/g1/jobs/+", 1)
+	tree.Add("$share/g1/jobs/+", 2)
+	tree.SetSharePicker(func(group, topic string, subscribers []any) any {
+		assert.Equal(t, "g1", group)
+		assert.Equal(t, "jobs/a", topic)
+		return subscribers[len(subscribers)-1]
+	})
+
+	assert.Equal(t, []any{2}, tree.Match("jobs/a"))
+	assert.Equal(t, []any{2}, tree.Match("jobs/a"))
+	assert.Equal(t, 2, tree.MatchFirst("jobs/a"))
+}
+
+func Test_TreeSystemTopics(t *testing.T) {
+	tree := NewStandardTree()
+
+	tree.Add("#", 1)
+	tree.Add("+/uptime", 2)
+	tree.Add("$SYS/#", 3)
+	tree.Add("$SYS/+", 4)
+	tree.Add("foo/#", 5)
+
+	assert.Equal(t, []any{3, 4}, tree.Match("$SYS/uptime"))
+	assert.Equal(t, []any{1, 2}, tree.Match("foo/uptime")[:2])
+	assert.Nil(t, tree.MatchFirst("$other/uptime"))
+	assert.ElementsMatch(t, []any{1, 2, 5}, tree.Search("#"))
+	assert.ElementsMatch(t, []any{2}, tree.Search("+/uptime"))
+	assert.ElementsMatch(t, []any{3, 4}, tree.Search("$SYS/#"))
+}
+
+type testSubscription struct {
+	client string
+	topics []string
+}
+
+func Test_TreeOf(t *testing.T) {
+	tree := NewStandardTreeOf(WithKey(func(s testSubscription) string { return s.client }))
+
+	a := testSubscription{client: "a", topics: []string{"foo/+"}}
+	tree.Add("foo/+", a)
+	tree.Add("foo/+", testSubscription{client: "a"})
+	tree.Add("foo/#", a)
+	tree.Add("foo/bar", testSubscription{client: "b"})
+
+	assert.Equal(t, 1, len(tree.Get("foo/+")))
+	assert.Equal(t, 3, tree.Count())
+
+	result := tree.Match("foo/bar")
+	assert.Equal(t, 2, len(result))
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{result[0].client, result[1].client})
+	assert.Equal(t, "a", tree.MatchFirst("foo/bar").client)
+	assert.Equal(t, 2, len(tree.All()))
+
+	tree.Clear(testSubscription{client: "a"})
+	assert.Equal(t, []testSubscription{{client: "b"}}, tree.Search("foo/#"))
+	assert.Equal(t, "", tree.SearchFirst("baz").client)
+}
+
+func Test_TreeOfEqual(t *testing.T) {
+	tree := NewStandardTreeOf(WithEqual(strings.EqualFold))
+
+	tree.Add("foo", "A")
+	tree.Add("foo", "a")
+	tree.Add("foo/bar", "b")
+	tree.Remove("foo/bar", "B")
+
+	assert.Equal(t, []string{"A"}, tree.Match("foo"))
+	assert.Equal(t, 1, tree.Count())
+}
+
+func Test_TreeSeq(t *testing.T) {
+	tree := NewStandardTreeOf[int]()
+
+	tree.Add("foo/#", 1)
+	tree.Add("foo/+", 1)
+	tree.Add("foo/bar", 2)
+
+	assert.ElementsMatch(t, []int{1, 1, 2}, slices.Collect(tree.MatchSeq("foo/bar")))
+	assert.ElementsMatch(t, []int{1, 1, 2}, slices.Collect(tree.SearchSeq("foo/#")))
+	assert.ElementsMatch(t, []int{1, 1, 2}, slices.Collect(tree.AllSeq()))
+
+	for _, seq := range []iter.Seq[int]{tree.MatchSeq("foo/bar"), tree.SearchSeq("foo/+"), tree.AllSeq()} {
+		n := 0
+		for range seq {
+			n++
+			break
+		}
+		assert.Equal(t, 1, n)
+	}
+
+	// the tree is unlocked after a break
+	tree.Add("baz", 3)
+	assert.Equal(t, []int{3}, tree.Match("baz"))
+}
+
 func Benchmark_TreeAddSame(b *testing.B) {
 	tree := NewStandardTree()
 
@@ -433,3 +570,101 @@ func Benchmark_TreeSearchWildcardSome(b *testing.B) {
 		tree.Search("#")
 	}
 }
+
+func Benchmark_TreeMatchSeqWildcardSome(b *testing.B) {
+	tree := NewStandardTree()
+	tree.Add("#", 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for range tree.MatchSeq("foo/bar") {
+		}
+	}
+}
+
+func Test_TreeCopyOnWrite(t *testing.T) {
+	tree := NewStandardTree(WithCopyOnWrite[any]())
+
+	tree.Add("foo/+", 1)
+	tree.Add("foo/bar", 2)
+	tree.Add("$share/g1/foo/#", 3)
+
+	// iterating over a snapshot allows to modify the tree
+	var result []any
+	for v := range tree.MatchSeq("foo/bar") {
+		tree.Remove("foo/bar", 2)
+		result = append(result, v)
+	}
+	assert.ElementsMatch(t, []any{1, 2, 3}, result)
+	assert.ElementsMatch(t, []any{1, 3}, tree.Match("foo/bar"))
+
+	tree.Set("foo/+", 4)
+	tree.Clear(3)
+	assert.Equal(t, []any{4}, tree.Search("foo/#"))
+	assert.Equal(t, 1, tree.Count())
+
+	tree.Empty("foo/+")
+	assert.Equal(t, 0, len(tree.root.children))
+
+	tree.Add("foo", 5)
+	tree.Reset()
+	assert.Nil(t, tree.Match("foo"))
+}
+
+func Test_TreeCopyOnWriteConcurrent(t *testing.T) {
+	tree := NewStandardTreeOf(WithCopyOnWrite[int]())
+	tree.Add("foo/#", 0)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 1000 {
+				assert.Contains(t, tree.Match("foo/bar"), 0)
+			}
+		})
+	}
+	for i := range 100 {
+		tree.Add(fmt.Sprintf("foo/%d", i%10), i)
+		tree.Remove(fmt.Sprintf("foo/%d", (i+5)%10), i-5)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 6, tree.Count())
+}
+
+func benchmarkTreeMatchParallel(b *testing.B, tree *Tree) {
+	for i := range 100 {
+		tree.Add(fmt.Sprintf("foo/%d/+", i), i)
+		tree.Add(fmt.Sprintf("foo/%d/#", i), i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tree.Match("foo/42/bar")
+		}
+	})
+}
+
+func Benchmark_TreeMatchParallel(b *testing.B) {
+	benchmarkTreeMatchParallel(b, NewStandardTree())
+}
+
+func Benchmark_TreeCopyOnWriteMatchParallel(b *testing.B) {
+	benchmarkTreeMatchParallel(b, NewStandardTree(WithCopyOnWrite[any]()))
+}
+
+func Benchmark_TreeCopyOnWriteAddUnique(b *testing.B) {
+	tree := NewStandardTree(WithCopyOnWrite[any]())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Add(fmt.Sprintf("foo/%d", i%1000), i)
+	}
+}
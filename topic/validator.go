@@ -0,0 +1,179 @@
+package topic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTopicLength is the maximum length of a MQTT topic in bytes.
+const MaxTopicLength = 65535
+
+var (
+	// ErrTooLong is returned by Validator.Validate if a topic exceeds the
+	// maximum length.
+	ErrTooLong = errors.New("topic too long")
+	// ErrTooManyLevels is returned by Validator.Validate, wrapped in a
+	// LevelError, if a topic exceeds the maximum number of levels.
+	ErrTooManyLevels = errors.New("too many topic levels")
+	// ErrInvalidUTF8 is returned by Validator.Validate, wrapped in a
+	// LevelError, if a level is not valid UTF-8.
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+	// ErrNullCharacter is returned by Validator.Validate, wrapped in a
+	// LevelError, if a level contains U+0000, which MQTT forbids.
+	ErrNullCharacter = errors.New("null character")
+	// ErrCharacter is returned by Validator.Validate, wrapped in a
+	// LevelError, if a level contains a character which is not allowed.
+	ErrCharacter = errors.New("character not allowed")
+)
+
+// LevelError is the error of Validator.Validate for an invalid level.
+type LevelError struct {
+	Level   int    // index of the level, starting at 0
+	Segment string // the invalid level
+	Err     error
+}
+
+func (e *LevelError) Error() string {
+	return fmt.Sprintf("topic level %d %q: %v", e.Level, e.Segment, e.Err)
+}
+
+func (e *LevelError) Unwrap() error { return e.Err }
+
+// ValidatorOption configures a Validator.
+type ValidatorOption func(*Validator)
+
+// WithMaxBytes caps the length of the topics in bytes, MaxTopicLength by
+// default. If maxBytes <= 0, the length is not limited.
+func WithMaxBytes(maxBytes int) ValidatorOption {
+	return func(v *Validator) {
+		v.maxBytes = maxBytes
+	}
+}
+
+// WithMaxLevels caps the number of levels of the topics, as brokers usually
+// do. If maxLevels <= 0, the default, the levels are not limited.
+func WithMaxLevels(maxLevels int) ValidatorOption {
+	return func(v *Validator) {
+		v.maxLevels = maxLevels
+	}
+}
+
+// WithAllowedChars rejects the characters of the levels for which allowed
+// returns false. U+0000 is always rejected.
+func WithAllowedChars(allowed func(r rune) bool) ValidatorOption {
+	return func(v *Validator) {
+		v.allowed = allowed
+	}
+}
+
+// A Validator validates and normalizes topics like Parse, with configurable
+// limits, and the separator and wildcards of a Tree. In contrast to Parse, it
+// also rejects invalid UTF-8 and U+0000, and the errors of a level are
+// returned as a LevelError.
+type Validator struct {
+	separator    string
+	wildcardOne  string
+	wildcardSome string
+	maxBytes     int
+	maxLevels    int
+	allowed      func(r rune) bool
+}
+
+// NewValidator returns a new Validator using the specified separator and
+// wildcards, as NewTree. It panics if the separator or a wildcard is empty.
+func NewValidator(separator, wildcardOne, wildcardSome string, opts ...ValidatorOption) *Validator {
+	if separator == "" || wildcardOne == "" || wildcardSome == "" {
+		panic("topic: empty validator separator or wildcard")
+	}
+	v := &Validator{
+		separator:    separator,
+		wildcardOne:  wildcardOne,
+		wildcardSome: wildcardSome,
+		maxBytes:     MaxTopicLength,
+	}
+	for _, f := range opts {
+		f(v)
+	}
+	return v
+}
+
+// NewStandardValidator returns a new Validator using the standard MQTT
+// separator and wildcards.
+func NewStandardValidator(opts ...ValidatorOption) *Validator {
+	return NewValidator("/", "+", "#", opts...)
+}
+
+// Validate removes duplicate and trailing separators from the supplied topic
+// and returns the normalized topic. Returns ErrZeroLength if the topic is
+// empty, ErrTooLong if it exceeds the maximum length, or a LevelError naming
+// the first invalid level.
+func (v *Validator) Validate(topic string, allowWildcards bool) (string, error) {
+	// check length
+	if topic == "" {
+		return "", ErrZeroLength
+	}
+	if v.maxBytes > 0 && len(topic) > v.maxBytes {
+		return "", fmt.Errorf("%w: %d bytes, max %d", ErrTooLong, len(topic), v.maxBytes)
+	}
+
+	// normalize topic
+	double := v.separator + v.separator
+	for strings.Contains(topic, double) {
+		topic = strings.ReplaceAll(topic, double, v.separator)
+	}
+	for strings.HasSuffix(topic, v.separator) {
+		topic = strings.TrimSuffix(topic, v.separator)
+	}
+
+	// check again for zero length
+	if topic == "" {
+		return "", ErrZeroLength
+	}
+
+	// check all segments
+	level := 0
+	for remainder := topic; ; level++ {
+		segment, rest, more := strings.Cut(remainder, v.separator)
+		if err := v.validateSegment(segment, allowWildcards, more); err != nil {
+			return "", &LevelError{Level: level, Segment: segment, Err: err}
+		}
+		if v.maxLevels > 0 && level >= v.maxLevels {
+			return "", &LevelError{Level: level, Segment: segment, Err: ErrTooManyLevels}
+		}
+		if !more {
+			break
+		}
+		remainder = rest
+	}
+
+	return topic, nil
+}
+
+func (v *Validator) validateSegment(segment string, allowWildcards, more bool) error {
+	// check use of wildcards
+	if segment == v.wildcardOne || segment == v.wildcardSome {
+		if !allowWildcards || (segment == v.wildcardSome && more) {
+			return ErrWildcards
+		}
+		return nil
+	}
+	if strings.Contains(segment, v.wildcardOne) || strings.Contains(segment, v.wildcardSome) {
+		return ErrWildcards
+	}
+
+	// check characters
+	if !utf8.ValidString(segment) {
+		return ErrInvalidUTF8
+	}
+	for _, r := range segment {
+		if r == 0 {
+			return ErrNullCharacter
+		}
+		if v.allowed != nil && !v.allowed(r) {
+			return fmt.Errorf("%w: %q", ErrCharacter, r)
+		}
+	}
+	return nil
+}
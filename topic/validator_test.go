@@ -0,0 +1,95 @@
+package topic
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Validator(t *testing.T) {
+	v := NewStandardValidator()
+
+	tests := map[string]string{
+		"topic/hello":         "topic/hello",
+		"//topic":             "/topic",
+		"topic///":            "topic",
+		"topic///cool//hello": "topic/cool/hello",
+		"topic/+/#":           "topic/+/#",
+		"tópic/日本":            "tópic/日本",
+	}
+	for str, result := range tests {
+		topic, err := v.Validate(str, true)
+		require.NoError(t, err, str)
+		require.Equal(t, result, topic)
+	}
+
+	_, err := v.Validate("", true)
+	require.Equal(t, ErrZeroLength, err)
+	_, err = v.Validate("///", true)
+	require.Equal(t, ErrZeroLength, err)
+	_, err = v.Validate(strings.Repeat("a", MaxTopicLength+1), true)
+	require.ErrorIs(t, err, ErrTooLong)
+	_, err = NewStandardValidator(WithMaxBytes(0)).Validate(strings.Repeat("a", MaxTopicLength+1), true)
+	require.NoError(t, err)
+}
+
+func Test_ValidatorLevelError(t *testing.T) {
+	v := NewStandardValidator(WithMaxLevels(3))
+
+	tests := []struct {
+		topic     string
+		wildcards bool
+		err       LevelError
+	}{
+		{"a/b/c/d", true, LevelError{3, "d", ErrTooManyLevels}},
+		{"a/b+/c", true, LevelError{1, "b+", ErrWildcards}},
+		{"a/#/c", true, LevelError{1, "#", ErrWildcards}},
+		{"a/+", false, LevelError{1, "+", ErrWildcards}},
+		{"a/b\x00", true, LevelError{1, "b\x00", ErrNullCharacter}},
+		{"\xff/b", true, LevelError{0, "\xff", ErrInvalidUTF8}},
+	}
+	for _, test := range tests {
+		_, err := v.Validate(test.topic, test.wildcards)
+		var levelErr *LevelError
+		require.ErrorAs(t, err, &levelErr, test.topic)
+		require.Equal(t, test.err, *levelErr, test.topic)
+		require.ErrorIs(t, err, test.err.Err, test.topic)
+	}
+
+	_, err := v.Validate("a/b/c", true)
+	require.NoError(t, err)
+	require.EqualError(t, &LevelError{1, "b+", ErrWildcards}, `topic level 1 "b+": invalid use of wildcards`)
+}
+
+func Test_ValidatorCustom(t *testing.T) {
+	v := NewValidator(".", "*", ">", WithAllowedChars(func(r rune) bool {
+		return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-')
+	}))
+
+	topic, err := v.Validate("orders..eu-west.*.>", true)
+	require.NoError(t, err)
+	require.Equal(t, "orders.eu-west.*.>", topic)
+
+	tree := NewTree(".", "*", ">")
+	tree.Add(topic, 1)
+	require.Equal(t, []any{1}, tree.Match("orders.eu-west.a.b"))
+
+	_, err = v.Validate("orders/eu", true)
+	var levelErr *LevelError
+	require.ErrorAs(t, err, &levelErr)
+	require.ErrorIs(t, err, ErrCharacter)
+	require.Equal(t, 0, levelErr.Level)
+
+	_, err = v.Validate("orders.+", true)
+	require.ErrorIs(t, err, ErrCharacter)
+	_, err = v.Validate("orders.a*", true)
+	require.ErrorIs(t, err, ErrWildcards)
+}
+
+func Test_ValidatorEmptySeparator(t *testing.T) {
+	require.Panics(t, func() { NewValidator("", "+", "#") })
+	require.Panics(t, func() { NewValidator("/", "", "#") })
+	require.Panics(t, func() { NewValidator("/", "+", "") })
+}